@@ -1,7 +1,9 @@
 package dana
 
 import (
+	"context"
 	"encoding/json"
+	"github.com/google/uuid"
 	"github.com/tidwall/gjson"
 	"io"
 	"io/ioutil"
@@ -20,20 +22,66 @@ type Client struct {
 	PrivateKey       []byte
 	PublicKey        []byte
 	LogLevel         int
-	Logger           Logger
+	Logger           LogInterface
 	SignatureEnabled bool
+	// Protocol is PROTOCOL_LEGACY (default) for the head/body/signature envelope of CoreGateway, or
+	// PROTOCOL_SNAP for the Bank Indonesia SNAP standard, required by SnapGateway. Responses are read the
+	// SNAP way only on SnapGateway's calls, whatever the protocol of the client.
+	Protocol string
+	// PartnerId is sent as X-PARTNER-ID on SNAP requests, ClientId is used when empty
+	PartnerId string
+	// ChannelId is sent as CHANNEL-ID on SNAP requests
+	ChannelId string
+	// Redactor masks secrets and PII in logged requests and responses, nil logs them unmasked
+	Redactor *Redactor
+	// Tracer records spans around gateway calls, nil disables tracing
+	Tracer Tracer
+	// Metrics records call counts, latencies and outcomes, nil disables metrics
+	Metrics Metrics
+	// Interceptors are run around every request sent by the client, see Interceptor
+	Interceptors []Interceptor
+	// HTTPClient sends the requests, nil uses a client with a 15 seconds timeout. Set its Transport
+	// to record or replay exchanges, see the replay package.
+	HTTPClient *http.Client
+	// Clock returns the time written in signed requests, nil uses time.Now
+	Clock func() time.Time
+	// ReqMsgIDGenerator returns the reqMsgId of a request sent with ctx, nil generates a random UUID (v4).
+	// A reqMsgId set with WithReqMsgID is used instead of calling it.
+	ReqMsgIDGenerator func(ctx context.Context) (string, error)
+}
+
+const (
+	PROTOCOL_LEGACY = "LEGACY"
+	PROTOCOL_SNAP   = "SNAP"
+)
+
+// LogInterface is what the library logs through. *Logger implements it, and any other logger can be
+// plugged into Client.Logger with a small adapter. Client.LogLevel decides which calls are made.
+type LogInterface interface {
+	Debug(format string, v ...interface{})
+	Info(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+	Error(format string, v ...interface{})
+}
+
+// FieldLogger is a logger that can write structured fields, such as *Logger. Call summaries are written
+// as fields to a FieldLogger and as key=value pairs in the message to any other LogInterface.
+type FieldLogger interface {
+	LogInterface
+	WithFields(fields map[string]interface{}) LogInterface
 }
 
 // NewClient : this function will always be called when the library is in use
 func NewClient() Client {
+	// the logger lets every level through, LogLevel decides what the client logs
 	logOption := LogOption{
 		Format:          "text",
-		Level:           "info",
-		TimestampFormat: "2006-01-02T15:04:05-0700",
+		Level:           LOG_LEVEL_DEBUG,
+		TimestampFormat: DEFAULT_LOG_TIMESTAMP_FORMAT,
 		CallerToggle:    false,
 	}
 
-	logger := *NewLogger(logOption)
+	logger := NewLogger(logOption)
 
 	return Client{
 		// LogLevel is the logging level used by the Dana library
@@ -44,6 +92,97 @@ func NewClient() Client {
 		LogLevel:         2,
 		Logger:           logger,
 		SignatureEnabled: true,
+		Protocol:         PROTOCOL_LEGACY,
+		Redactor:         NewRedactor(),
+	}
+}
+
+type reqMsgIDKey struct{}
+
+// WithReqMsgID : send every request made with the returned context with reqMsgID, e.g. to correlate it with
+// a request ID of the caller. DANA expects a reqMsgId per request, so derive a new context for each call
+// instead of reusing one across calls.
+func WithReqMsgID(ctx context.Context, reqMsgID string) context.Context {
+	return context.WithValue(ctx, reqMsgIDKey{}, reqMsgID)
+}
+
+func (c *Client) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+
+	return time.Now()
+}
+
+// newReqMsgID returns the reqMsgId set on ctx, or a new one from ReqMsgIDGenerator
+func (c *Client) newReqMsgID(ctx context.Context) (string, error) {
+	if id, ok := ctx.Value(reqMsgIDKey{}).(string); ok && id != "" {
+		return id, nil
+	}
+
+	if c.ReqMsgIDGenerator != nil {
+		return c.ReqMsgIDGenerator(ctx)
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	return id.String(), nil
+}
+
+type loggerKey struct{}
+
+// WithLogger : log the calls made with ctx to logger instead of Client.Logger, e.g. a logger carrying the
+// fields of the caller's request. LogLevel still decides what is logged.
+func WithLogger(ctx context.Context, logger LogInterface) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// logger returns the logger set on ctx with WithLogger, or Client.Logger. A *Logger is derived with
+// Logger.Ctx, so that its lines carry the req_id found in ctx.
+func (c *Client) logger(ctx context.Context) LogInterface {
+	if logger, ok := ctx.Value(loggerKey{}).(LogInterface); ok && logger != nil {
+		return logger
+	}
+
+	if logger, ok := c.Logger.(*Logger); ok && logger != nil {
+		return logger.Ctx(ctx)
+	}
+
+	return c.Logger
+}
+
+func (c *Client) logError(ctx context.Context, format string, v ...interface{}) {
+	if c.LogLevel >= 1 {
+		if logger := c.logger(ctx); logger != nil {
+			logger.Error(format, v...)
+		}
+	}
+}
+
+func (c *Client) logWarn(ctx context.Context, format string, v ...interface{}) {
+	if c.LogLevel >= 1 {
+		if logger := c.logger(ctx); logger != nil {
+			logger.Warn(format, v...)
+		}
+	}
+}
+
+func (c *Client) logInfo(ctx context.Context, format string, v ...interface{}) {
+	if c.LogLevel >= 2 {
+		if logger := c.logger(ctx); logger != nil {
+			logger.Info(format, v...)
+		}
+	}
+}
+
+func (c *Client) logDebug(ctx context.Context, format string, v ...interface{}) {
+	if c.LogLevel >= 3 {
+		if logger := c.logger(ctx); logger != nil {
+			logger.Debug(format, v...)
+		}
 	}
 }
 
@@ -51,11 +190,23 @@ func NewClient() Client {
 var defHTTPTimeout = 15 * time.Second
 var httpClient = &http.Client{Timeout: defHTTPTimeout}
 
+func (c *Client) getHTTPClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+
+	return httpClient
+}
+
 // NewRequest : send new request
 func (c *Client) NewRequest(method string, fullPath string, headers map[string]string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, fullPath, body)
+	return c.newRequest(context.Background(), method, fullPath, headers, body)
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, fullPath string, headers map[string]string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, fullPath, body)
 	if err != nil {
-		c.Logger.Info("Request creation failed: %v ", err)
+		c.logError(ctx, "Request creation failed: %v ", err)
 		return nil, err
 	}
 
@@ -69,39 +220,135 @@ func (c *Client) NewRequest(method string, fullPath string, headers map[string]s
 }
 
 // ExecuteRequest : execute request
-func (c *Client) ExecuteRequest(req *http.Request, v interface{}) error {
-	c.Logger.Info("Start requesting: %v ", req.URL)
+func (c *Client) ExecuteRequest(req *http.Request, v interface{}) (err error) {
+	ctx := req.Context()
+	summary := callSummaryFromContext(ctx)
+	summary.Path = req.URL.Path
+	exchange := &Exchange{Function: summary.Function, Request: req}
 
-	command, _ := http2curl.GetCurlCommand(req)
+	// failures are logged at error level once, by the call summary, the lines below only add detail at debug level
 	start := time.Now()
-	res, err := httpClient.Do(req)
-	if err != nil {
-		c.Logger.Error("Request failed. Error : %v , Curl Request : %v", err, command)
+	defer func() {
+		if err != nil {
+			c.interceptError(exchange, err)
+		}
+
+		summary.Latency = time.Since(start)
+		summary.Err = err
+		c.logCall(ctx, summary)
+		c.observeCall(summary)
+	}()
+
+	if len(c.Interceptors) > 0 {
+		if exchange.Envelope, err = readBody(req); err != nil {
+			return err
+		}
+	}
+
+	if err = c.beforeSend(exchange); err != nil {
+		c.logDebug(ctx, "Request aborted by interceptor: %v ", err)
 		return err
 	}
-	defer res.Body.Close()
 
-	c.Logger.Info("Completed in %v", time.Since(start))
-	c.Logger.Info("Curl Request: %v ", command)
+	c.logDebug(ctx, "Start requesting: %v ", req.URL)
 
-	resBody, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		c.Logger.Error("Cannot read response body: %v ", err)
+	command := curlCommand{client: c, req: req}
+
+	// an interceptor may answer in place of DANA, e.g. to inject faults
+	if exchange.Response == nil {
+		_, httpSpan := c.startSpan(req.Context(), SPAN_HTTP)
+		httpSpan.SetAttribute(ATTRIBUTE_HTTP_METHOD, req.Method)
+
+		res, err := c.getHTTPClient().Do(req)
+		if err != nil {
+			endSpan(httpSpan, err)
+			c.logDebug(ctx, "Request failed. Error : %v , Curl Request : %v", err, command)
+			return err
+		}
+		defer res.Body.Close()
+
+		httpSpan.SetAttribute(ATTRIBUTE_HTTP_STATUS_CODE, res.StatusCode)
+
+		exchange.Response = res
+		exchange.ResponseBody, err = ioutil.ReadAll(res.Body)
+		endSpan(httpSpan, err)
+		if err != nil {
+			c.logDebug(ctx, "Cannot read response body: %v ", err)
+			return err
+		}
+	}
+
+	summary.HTTPStatus = exchange.Response.StatusCode
+	c.logDebug(ctx, "Curl Request: %v ", command)
+
+	summary.setResult(exchange.ResponseBody)
+	if raw, ok := v.(rawBodySetter); ok {
+		raw.setRawBody(exchange.ResponseBody)
+	}
+	c.logDebug(ctx, "DANA response body : %s", string(c.Redactor.JSON(exchange.ResponseBody)))
+
+	if err = c.decodeResponse(req, exchange.Response.StatusCode, exchange.ResponseBody, v, summary); err != nil {
 		return err
 	}
 
-	c.Logger.Info("DANA HTTP status response : %d", res.StatusCode)
-	c.Logger.Info("DANA response body : %s", string(resBody))
+	exchange.Result = v
+	return c.afterReceive(exchange)
+}
+
+// curlCommand formats a request as a redacted curl command. It is only built when a line logging it is written.
+type curlCommand struct {
+	client *Client
+	req    *http.Request
+}
+
+func (cmd curlCommand) String() string {
+	req := cmd.req
+	// the body has been sent already, GetBody returns a fresh copy of it
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+
+	command, err := http2curl.GetCurlCommand(cmd.client.Redactor.Request(req))
+	if err != nil {
+		return ""
+	}
+	return command.String()
+}
+
+// rawBodySetter is implemented by the typed responses through RawBody
+type rawBodySetter interface {
+	setRawBody(body []byte)
+}
 
-	if v != nil && res.StatusCode == 200 {
+// decodeResponse unmarshals resBody into v and verifies its signature when the protocol requires it
+func (c *Client) decodeResponse(req *http.Request, statusCode int, resBody []byte, v interface{}, summary *callSummary) (err error) {
+	ctx := req.Context()
+
+	// SNAP reports failures through the HTTP status and the responseCode in the body, and its responses
+	// are not wrapped in a signed envelope
+	if v != nil && isSnapCall(ctx) {
+		if len(resBody) == 0 {
+			return nil
+		}
+
+		if err = json.Unmarshal(resBody, v); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if v != nil && statusCode == 200 {
 		if err = json.Unmarshal(resBody, v); err != nil {
-			c.Logger.Error("Failed unmarshal body: %v ", err)
 			return err
 		}
 
 		// Dana endpoint V1 doesn't return signature in response, so we don't need to verify signature again
 		if strings.Contains(req.URL.String(), "/v1/") {
-			c.Logger.Info("Req URL Contains Dana endpoint V1")
+			c.logDebug(ctx, "Req URL Contains Dana endpoint V1")
 			return nil
 		}
 
@@ -109,9 +356,11 @@ func (c *Client) ExecuteRequest(req *http.Request, v interface{}) error {
 			response := gjson.Get(string(resBody), "response")
 			signature := gjson.Get(string(resBody), "signature")
 
+			_, verifySpan := c.startSpan(ctx, SPAN_VERIFY)
 			err = verifySignature(response.String(), signature.String(), c.PublicKey)
+			endSpan(verifySpan, err)
 			if err != nil {
-				c.Logger.Error("verifySignature failed: %v ", err)
+				c.observeSignatureFailure(summary.Function)
 				return err
 			}
 		}
@@ -124,7 +373,12 @@ func (c *Client) ExecuteRequest(req *http.Request, v interface{}) error {
 // given to `v` if there is no error. If any error occurred, the return of this function is the error
 // itself, otherwise nil.
 func (c *Client) Call(method, path string, header map[string]string, body io.Reader, v interface{}) error {
-	req, err := c.NewRequest(method, path, header, body)
+	return c.CallContext(context.Background(), method, path, header, body, v)
+}
+
+// CallContext : same as Call, sending the request with ctx
+func (c *Client) CallContext(ctx context.Context, method, path string, header map[string]string, body io.Reader, v interface{}) error {
+	req, err := c.newRequest(ctx, method, path, header, body)
 	if err != nil {
 		return err
 	}
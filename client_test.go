@@ -0,0 +1,274 @@
+package dana
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *recordingLogger) record(level string, format string, v ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, level+" "+fmt.Sprintf(format, v...))
+}
+
+func (r *recordingLogger) Debug(format string, v ...interface{}) { r.record("debug", format, v...) }
+func (r *recordingLogger) Info(format string, v ...interface{})  { r.record("info", format, v...) }
+func (r *recordingLogger) Warn(format string, v ...interface{})  { r.record("warn", format, v...) }
+func (r *recordingLogger) Error(format string, v ...interface{}) { r.record("error", format, v...) }
+
+func (r *recordingLogger) levels() map[string]int {
+	levels := map[string]int{}
+	for _, line := range r.lines {
+		levels[strings.SplitN(line, " ", 2)[0]]++
+	}
+	return levels
+}
+
+func TestLogLevel(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		return OrderDetailData{ResultInfo: ResultInfo{ResultStatus: "S"}}
+	})
+	defer fake.Close()
+
+	for level, want := range map[int][]string{0: nil, 1: nil, 2: {"info"}, 3: {"debug", "info"}} {
+		logger := &recordingLogger{}
+		gateway := fake.gateway()
+		gateway.Client.Logger = logger
+		gateway.Client.LogLevel = level
+
+		_, err := gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m", MerchantTransID: "ORDER-1"}, "")
+		require.NoError(t, err)
+
+		var got []string
+		for name := range logger.levels() {
+			got = append(got, name)
+		}
+		assert.ElementsMatch(t, want, got, "LogLevel %d", level)
+
+		for _, line := range logger.lines {
+			assert.NotContains(t, line, "test-secret")
+		}
+	}
+
+	// a failed call is logged once, by the call summary carrying the error
+	logger := &recordingLogger{}
+	gateway := fake.gateway()
+	gateway.Client.Logger = logger
+	gateway.Client.LogLevel = 1
+	gateway.Client.PublicKey = newTestKeyPair(t).public
+
+	_, err := gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m", MerchantTransID: "ORDER-1"}, "")
+	require.Error(t, err)
+	assert.Equal(t, map[string]int{"error": 1}, logger.levels())
+	require.Len(t, logger.lines, 1)
+	assert.Contains(t, logger.lines[0], LOG_MESSAGE_CALL_SUMMARY)
+
+	// so is a transport failure
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	logger = &recordingLogger{}
+	gateway = fake.gateway()
+	gateway.Client.Logger = logger
+	gateway.Client.LogLevel = 1
+	gateway.Client.BaseUrl = closed.URL
+
+	_, err = gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m", MerchantTransID: "ORDER-1"}, "")
+	require.Error(t, err)
+	assert.Equal(t, map[string]int{"error": 1}, logger.levels())
+	require.Len(t, logger.lines, 1)
+	assert.Contains(t, logger.lines[0], LOG_MESSAGE_CALL_SUMMARY)
+}
+
+func TestCurlCommandIsBuiltOnlyWhenLogged(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	for level, built := range map[int]bool{1: false, 2: false, 3: true} {
+		logger := &recordingLogger{}
+		client := NewClient()
+		client.Logger = logger
+		client.LogLevel = level
+
+		req, err := client.NewRequest("POST", server.URL, nil, bytes.NewReader([]byte(`{"a":1}`)))
+		require.NoError(t, err)
+
+		getBody, calls := req.GetBody, 0
+		req.GetBody = func() (io.ReadCloser, error) {
+			calls++
+			return getBody()
+		}
+
+		require.NoError(t, client.ExecuteRequest(req, nil))
+		assert.Equal(t, built, calls > 0, "LogLevel %d", level)
+		if built {
+			assert.Contains(t, strings.Join(logger.lines, "\n"), `curl -X 'POST' -d '{"a":1}'`)
+		}
+	}
+}
+
+func TestCallSummary(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		return OrderResponseData{ResultInfo: ResultInfo{ResultStatus: "S", ResultCodeID: "00000000"}}
+	})
+	defer fake.Close()
+
+	out := &syncBuffer{}
+	gateway := fake.gateway()
+	gateway.Client.Logger = NewLogger(LogOption{Level: LOG_LEVEL_DEBUG, Output: out})
+
+	_, err := gateway.Order(&OrderRequestData{Order: Order{MerchantTransID: "ORDER-1", OrderAmount: Amount{Value: "100"}}}, "")
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.buf.String()), "\n")
+	require.Len(t, lines, 1, "only the summary is logged at the default LogLevel")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, LOG_MESSAGE_CALL_SUMMARY, line["message"])
+	assert.Equal(t, FUNCTION_CREATE_ORDER, line[LOG_KEY_FUNCTION])
+	assert.Equal(t, "/"+ORDER_PATH, line[LOG_KEY_PATH])
+	assert.Equal(t, "ORDER-1", line[LOG_KEY_MERCHANT_TRANS_ID])
+	assert.NotEmpty(t, line[LOG_KEY_REQ_MSG_ID])
+	assert.EqualValues(t, 200, line[LOG_KEY_HTTP_STATUS])
+	assert.Equal(t, "S", line[LOG_KEY_RESULT_STATUS])
+	assert.Equal(t, "00000000", line[LOG_KEY_RESULT_CODE_ID])
+	assert.Contains(t, line, LOG_KEY_LATENCY_MS)
+	assert.EqualValues(t, 0, line[LOG_KEY_RETRIES])
+}
+
+type recordingMetrics struct {
+	calls             []CallMetric
+	signatureFailures []string
+	webhooks          []string
+}
+
+func (r *recordingMetrics) ObserveCall(call CallMetric) { r.calls = append(r.calls, call) }
+func (r *recordingMetrics) SignatureVerificationFailed(fn string) {
+	r.signatureFailures = append(r.signatureFailures, fn)
+}
+func (r *recordingMetrics) WebhookReceived(kind string, out string) {
+	r.webhooks = append(r.webhooks, kind+":"+out)
+}
+
+func TestMetricsHooks(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		return RefundResponseData{ResultInfo: ResultInfo{ResultStatus: "F", ResultCodeID: "12005110"}}
+	})
+	defer fake.Close()
+
+	metrics := &recordingMetrics{}
+	gateway := fake.gateway()
+	gateway.Client.Metrics = metrics
+
+	_, err := gateway.Refund(&RefundRequestData{RequestID: "refund-1", RefundAmount: Amount{Value: "100"}}, "")
+	require.NoError(t, err)
+
+	// a response signed with another key fails verification
+	gateway.Client.PublicKey = fake.merchant.public
+	_, err = gateway.Refund(&RefundRequestData{RequestID: "refund-2", RefundAmount: Amount{Value: "100"}}, "")
+	require.Error(t, err)
+
+	require.Len(t, metrics.calls, 2)
+	assert.Equal(t, FUNCTION_REFUND, metrics.calls[0].Function)
+	assert.Equal(t, 200, metrics.calls[0].HTTPStatus)
+	assert.Equal(t, "F", metrics.calls[0].ResultStatus)
+	assert.Equal(t, "12005110", metrics.calls[0].ResultCodeID)
+	assert.NoError(t, metrics.calls[0].Err)
+	assert.Error(t, metrics.calls[1].Err)
+	assert.Equal(t, []string{FUNCTION_REFUND}, metrics.signatureFailures)
+
+	notification := []byte(`{"request":{"head":{"function":"dana.acquiring.order.finishNotify"},"body":{}}}`)
+	_ = gateway.VerifySignature(notification, "bad")
+	assert.Equal(t, []string{"dana.acquiring.order.finishNotify:" + WEBHOOK_OUTCOME_INVALID_SIGNATURE}, metrics.webhooks)
+}
+
+func TestInterceptors(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		return OrderDetailData{ResultInfo: ResultInfo{ResultStatus: "S"}, AcquirementID: "acq-1"}
+	})
+	defer fake.Close()
+
+	var calls []string
+	var envelope string
+	var result interface{}
+
+	gateway := fake.gateway()
+	gateway.Client.Interceptors = []Interceptor{
+		{
+			BeforeSend: func(exchange *Exchange) error {
+				calls = append(calls, "before-1 "+exchange.Function)
+				exchange.Request.Header.Set("X-Audit-Id", "audit-1")
+				envelope = string(exchange.Envelope)
+				return nil
+			},
+			AfterReceive: func(exchange *Exchange) error {
+				calls = append(calls, "after-1")
+				result = exchange.Result
+				return nil
+			},
+		},
+		{
+			BeforeSend: func(exchange *Exchange) error {
+				calls = append(calls, "before-2")
+				return nil
+			},
+			AfterReceive: func(exchange *Exchange) error {
+				calls = append(calls, "after-2")
+				return nil
+			},
+		},
+	}
+
+	_, err := gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m", MerchantTransID: "ORDER-1"}, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"before-1 " + FUNCTION_QUERY_ORDER, "before-2", "after-2", "after-1"}, calls)
+	assert.Equal(t, "audit-1", fake.lastHeader().Get("X-Audit-Id"))
+	assert.Contains(t, envelope, `"signature":`)
+	assert.Contains(t, envelope, `"merchantTransId":"ORDER-1"`)
+	require.IsType(t, &OrderDetailResponse{}, result)
+	assert.Equal(t, "acq-1", result.(*OrderDetailResponse).Response.Body.AcquirementID)
+}
+
+func TestInterceptorFaultInjection(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		t.Error("the request should not reach DANA")
+		return nil
+	})
+	defer fake.Close()
+
+	var onError error
+	gateway := fake.gateway()
+	gateway.Client.Interceptors = []Interceptor{{
+		BeforeSend: func(exchange *Exchange) error {
+			exchange.Response = &http.Response{StatusCode: http.StatusOK}
+			exchange.ResponseBody = []byte(`{"response":{"head":{},"body":{}},"signature":"forged"}`)
+			return nil
+		},
+		OnError: func(exchange *Exchange, err error) {
+			onError = err
+		},
+	}}
+
+	_, err := gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m"}, "")
+	require.Error(t, err, "the injected response is not signed by DANA")
+	assert.Equal(t, err, onError)
+}
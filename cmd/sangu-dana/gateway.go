@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+
+	dana "github.com/kitabisa/sangu-dana"
+)
+
+func init() {
+	commands["order"] = command{usage: "create an order from a JSON OrderRequestData, amounts in rupiah", run: runOrder}
+	commands["query"] = command{usage: "query an order by acquirement or merchant transaction id", run: runQuery}
+	commands["refund"] = command{usage: "refund an order from a JSON RefundRequestData, amounts in rupiah", run: runRefund}
+	commands["apply-token"] = command{usage: "exchange an auth code or refresh token for an access token", run: runApplyToken}
+	commands["user-profile"] = command{usage: "query the profile of the user owning -access-token", run: runUserProfile}
+	commands["inquiry-user-info"] = command{usage: "query the user info of the user owning -access-token", run: runInquiryUserInfo}
+}
+
+func runOrder(env *environment, args []string) error {
+	var g gatewayFlags
+	fs := newFlagSet(env, "order")
+	g.register(fs)
+	body := fs.String("body", "", "JSON file with the OrderRequestData, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var req dana.OrderRequestData
+	if err := readBody(env, *body, &req); err != nil {
+		return err
+	}
+
+	gateway, err := g.gateway(env)
+	if err != nil {
+		return err
+	}
+
+	res, err := gateway.Order(&req, g.accessToken)
+	return finish(env, res.Raw, res, err)
+}
+
+func runQuery(env *environment, args []string) error {
+	var g gatewayFlags
+	fs := newFlagSet(env, "query")
+	g.register(fs)
+	var req dana.OrderDetailRequestData
+	fs.StringVar(&req.MerchantID, "merchant-id", "", "merchant id (required)")
+	fs.StringVar(&req.AcquirementID, "acquirement-id", "", "DANA acquirement id")
+	fs.StringVar(&req.MerchantTransID, "merchant-trans-id", "", "merchant transaction id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if req.MerchantID == "" || (req.AcquirementID == "" && req.MerchantTransID == "") {
+		return errors.New("-merchant-id and one of -acquirement-id or -merchant-trans-id are required")
+	}
+
+	gateway, err := g.gateway(env)
+	if err != nil {
+		return err
+	}
+
+	res, err := gateway.OrderDetail(&req, g.accessToken)
+	return finish(env, res.Raw, res, err)
+}
+
+func runRefund(env *environment, args []string) error {
+	var g gatewayFlags
+	fs := newFlagSet(env, "refund")
+	g.register(fs)
+	body := fs.String("body", "", "JSON file with the RefundRequestData, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var req dana.RefundRequestData
+	if err := readBody(env, *body, &req); err != nil {
+		return err
+	}
+
+	gateway, err := g.gateway(env)
+	if err != nil {
+		return err
+	}
+
+	res, err := gateway.Refund(&req, g.accessToken)
+	return finish(env, res.Raw, res, err)
+}
+
+func runApplyToken(env *environment, args []string) error {
+	var g gatewayFlags
+	fs := newFlagSet(env, "apply-token")
+	g.register(fs)
+	var req dana.RequestApplyAccessToken
+	fs.StringVar(&req.GrantType, "grant-type", "AUTHORIZATION_CODE", "AUTHORIZATION_CODE or REFRESH_TOKEN")
+	fs.StringVar(&req.AuthCode, "auth-code", "", "auth code returned by DANA's OAuth page")
+	fs.StringVar(&req.RefreshToken, "refresh-token", "", "refresh token of a previous access token")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if req.AuthCode == "" && req.RefreshToken == "" {
+		return errors.New("-auth-code or -refresh-token is required")
+	}
+
+	gateway, err := g.gateway(env)
+	if err != nil {
+		return err
+	}
+
+	res, err := gateway.ApplyAccessToken(&req)
+	return finish(env, res.Raw, res, err)
+}
+
+func runUserProfile(env *environment, args []string) error {
+	var g gatewayFlags
+	fs := newFlagSet(env, "user-profile")
+	g.register(fs)
+	resources := fs.String("resources", "BALANCE", "comma separated user resources to query")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if g.accessToken == "" {
+		return errors.New("-access-token is required")
+	}
+
+	gateway, err := g.gateway(env)
+	if err != nil {
+		return err
+	}
+
+	res, err := gateway.UserProfile(&dana.UserProfileRequestData{UserResources: splitList(*resources)}, g.accessToken)
+	return finish(env, res.Raw, res, err)
+}
+
+func runInquiryUserInfo(env *environment, args []string) error {
+	var g gatewayFlags
+	fs := newFlagSet(env, "inquiry-user-info")
+	g.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if g.accessToken == "" {
+		return errors.New("-access-token is required")
+	}
+
+	gateway, err := g.gateway(env)
+	if err != nil {
+		return err
+	}
+
+	res, err := gateway.InquiryUserInfo(&dana.InquiryUserInfoRequest{AccessToken: g.accessToken}, g.accessToken)
+	return finish(env, res.Raw, res, err)
+}
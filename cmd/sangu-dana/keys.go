@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	PUBLIC_KEY_FORMAT_BASE64 = "base64"
+	PUBLIC_KEY_FORMAT_PEM    = "pem"
+)
+
+// signedObjects are the envelope members DANA signs: requests and notifications sign "request", responses sign "response"
+var signedObjects = []string{"request", "response"}
+
+func init() {
+	commands["gen-key"] = command{usage: "generate a merchant RSA key pair", run: runGenKey}
+	commands["public-key"] = command{usage: "print the public key of a private key, as registered on DANA's portal", run: runPublicKey}
+	commands["sign"] = command{usage: "sign the request of an envelope file with a private key", run: runSign}
+	commands["verify"] = command{usage: "verify a captured DANA response or notification against a public key", run: runVerify}
+}
+
+func runGenKey(env *environment, args []string) error {
+	fs := newFlagSet(env, "gen-key")
+	out := fs.String("out", DEFAULT_PRIVATE_KEY_FILE, "file the private key is written to")
+	publicOut := fs.String("public-out", "my_public.pem", "file the public key is written to")
+	bits := fs.Int("bits", dana.DEFAULT_KEY_BITS, "key size")
+	force := fs.Bool("force", false, "overwrite existing files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !*force {
+		for _, file := range []string{*out, *publicOut} {
+			if _, err := os.Stat(file); err == nil {
+				return fmt.Errorf("%s already exists, use -force to overwrite it", file)
+			}
+		}
+	}
+
+	privateKey, publicKey, err := dana.GenerateKeyPair(*bits)
+	if err != nil {
+		return err
+	}
+
+	if err = ioutil.WriteFile(*out, privateKey, 0600); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(*publicOut, publicKey, 0644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.stdout, "private key (PKCS#1 RSA PRIVATE KEY, keep it secret): %s\n", *out)
+	fmt.Fprintf(env.stdout, "public key (PKIX PUBLIC KEY, register it on DANA's portal): %s\n", *publicOut)
+	return nil
+}
+
+func runPublicKey(env *environment, args []string) error {
+	fs := newFlagSet(env, "public-key")
+	keyFile := fs.String("key", DEFAULT_PRIVATE_KEY_FILE, "PEM private key file")
+	format := fs.String("format", PUBLIC_KEY_FORMAT_BASE64, "base64 prints the key on one line without the PEM armour as the portal expects, pem prints the PEM block")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	privateKey, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := dana.PublicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *keyFile, err)
+	}
+
+	switch *format {
+	case PUBLIC_KEY_FORMAT_PEM:
+		_, err = env.stdout.Write(publicKey)
+	case PUBLIC_KEY_FORMAT_BASE64:
+		block, _ := pem.Decode(publicKey)
+		_, err = fmt.Fprintln(env.stdout, base64.StdEncoding.EncodeToString(block.Bytes))
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+
+	return err
+}
+
+func runSign(env *environment, args []string) error {
+	fs := newFlagSet(env, "sign")
+	keyFile := fs.String("key", DEFAULT_PRIVATE_KEY_FILE, "PEM private key file")
+	envelopeFile := fs.String("envelope", "", `JSON file with {"request": {...}} or the request {"head": ..., "body": ...} itself, - for stdin`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := readInput(env, *envelopeFile, "-envelope")
+	if err != nil {
+		return err
+	}
+
+	request := gjson.GetBytes(data, "request")
+	if !request.Exists() {
+		request = gjson.ParseBytes(data)
+	}
+	if !request.IsObject() {
+		return errors.New("the envelope has no request object")
+	}
+
+	// the request is compacted so the bytes sent are the bytes signed
+	var signed bytes.Buffer
+	if err = json.Compact(&signed, []byte(request.Raw)); err != nil {
+		return err
+	}
+
+	privateKey, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	sig, err := dana.SignPayload(signed.Bytes(), privateKey)
+	if err != nil {
+		return err
+	}
+
+	explain(env.stderr, "request", signed.Bytes())
+	fmt.Fprintln(env.stderr, "send the envelope below exactly as printed, any change to the request bytes invalidates the signature")
+
+	// written by hand, json.Marshal would escape characters such as < and change the signed bytes
+	_, err = fmt.Fprintf(env.stdout, "{\"request\":%s,\"signature\":%q}\n", signed.Bytes(), sig)
+	return err
+}
+
+func runVerify(env *environment, args []string) error {
+	fs := newFlagSet(env, "verify")
+	keyFile := fs.String("public-key", DEFAULT_PUBLIC_KEY_FILE, "PEM public key file, DANA's key for responses and notifications")
+	file := fs.String("file", "", "captured response or notification body, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := readInput(env, *file, "-file")
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	name, object := "", gjson.Result{}
+	for _, candidate := range signedObjects {
+		if object = gjson.GetBytes(data, candidate); object.Exists() {
+			name = candidate
+			break
+		}
+	}
+	if name == "" {
+		return errors.New(`no "request" or "response" object found, is this a DANA envelope?`)
+	}
+
+	sig := gjson.GetBytes(data, "signature").String()
+	if sig == "" {
+		return errors.New(`no "signature" found next to the signed object`)
+	}
+
+	signed := []byte(object.Raw)
+	explain(env.stdout, name, signed)
+
+	err = dana.VerifyPayload(signed, sig, publicKey)
+	if err == nil {
+		fmt.Fprintln(env.stdout, "signature: valid")
+		return nil
+	}
+
+	fmt.Fprintf(env.stdout, "signature: INVALID (%v)\n", err)
+
+	// the most common cause is a capture that was pretty printed or reformatted after it was received
+	var compacted bytes.Buffer
+	if json.Compact(&compacted, signed) == nil && !bytes.Equal(compacted.Bytes(), signed) &&
+		dana.VerifyPayload(compacted.Bytes(), sig, publicKey) == nil {
+		fmt.Fprintf(env.stdout, "the compacted %s matches the signature: the capture was reformatted, verify the bytes exactly as DANA sent them\n", name)
+	} else {
+		fmt.Fprintln(env.stdout, "check that the public key is DANA's key for this environment and that the body was captured before any parsing")
+	}
+
+	return errors.New("signature does not match")
+}
+
+// explain describes the bytes covered by a signature
+func explain(w io.Writer, name string, signed []byte) {
+	fmt.Fprintf(w, "signed object: %q, SHA256withRSA over its exact bytes as they appear in the envelope\n", name)
+	fmt.Fprintf(w, "signed bytes (%d): %s\n", len(signed), signed)
+	fmt.Fprintf(w, "sha256: %x\n", sha256.Sum256(signed))
+}
+
+// readInput reads file, "-" reads stdin
+func readInput(env *environment, file string, flagName string) ([]byte, error) {
+	if file == "" {
+		return nil, fmt.Errorf("%s is required", flagName)
+	}
+
+	if file == "-" {
+		return ioutil.ReadAll(env.stdin)
+	}
+
+	return ioutil.ReadFile(file)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestKeyCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sangu-dana-keys")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, "merchant.pem")
+	public := filepath.Join(dir, "merchant_public.pem")
+
+	code, _, stderr := runCommand("gen-key", "-out", private, "-public-out", public)
+	require.Equal(t, 0, code, stderr)
+
+	code, _, stderr = runCommand("gen-key", "-out", private, "-public-out", public)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "already exists")
+
+	publicPEM, err := ioutil.ReadFile(public)
+	require.NoError(t, err)
+	block, _ := pem.Decode(publicPEM)
+
+	code, stdout, stderr := runCommand("public-key", "-key", private)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(block.Bytes), strings.TrimSpace(stdout))
+
+	code, stdout, _ = runCommand("public-key", "-key", private, "-format", "pem")
+	require.Equal(t, 0, code)
+	assert.Equal(t, string(publicPEM), stdout)
+
+	envelope := filepath.Join(dir, "envelope.json")
+	require.NoError(t, ioutil.WriteFile(envelope, []byte(`{
+		"request": {
+			"head": {"function": "dana.acquiring.order.query", "reqMsgId": "1"},
+			"body": {"merchantId": "m", "note": "<b>"}
+		}
+	}`), 0600))
+
+	code, signed, stderr := runCommand("sign", "-key", private, "-envelope", envelope)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, `signed bytes (104): {"head":{"function":"dana.acquiring.order.query","reqMsgId":"1"},"body":{"merchantId":"m","note":"<b>"}}`)
+	require.NoError(t, dana.VerifyPayload([]byte(gjson.Get(signed, "request").Raw), gjson.Get(signed, "signature").String(), publicPEM))
+
+	captured := filepath.Join(dir, "captured.json")
+	require.NoError(t, ioutil.WriteFile(captured, []byte(signed), 0600))
+
+	code, stdout, _ = runCommand("verify", "-public-key", public, "-file", captured)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, `signed object: "request"`)
+	assert.Contains(t, stdout, "signature: valid")
+
+	// the same capture pretty printed by a tool
+	pretty := strings.Replace(signed, `"body":{`, `"body": {`, 1)
+	require.NoError(t, ioutil.WriteFile(captured, []byte(pretty), 0600))
+
+	code, stdout, stderr = runCommand("verify", "-public-key", public, "-file", captured)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "signature: INVALID")
+	assert.Contains(t, stdout, "the capture was reformatted")
+	assert.Contains(t, stderr, "signature does not match")
+}
+
+func TestNotifyCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sangu-dana-notify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	danaPrivate, danaPublic, err := dana.GenerateKeyPair(dana.DEFAULT_KEY_BITS)
+	require.NoError(t, err)
+	danaKey := filepath.Join(dir, "dana_test_private.pem")
+	require.NoError(t, ioutil.WriteFile(danaKey, danaPrivate, 0600))
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+
+		gateway := dana.CoreGateway{Client: dana.Client{PublicKey: danaPublic}}
+		if gateway.VerifySignature(body, gjson.GetBytes(body, "signature").String()) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"response":{"head":{},"body":{"resultInfo":{"resultStatus":"F"}}},"signature":"x"}`))
+	}))
+	defer server.Close()
+
+	code, stdout, stderr := runCommand("notify", "-url", server.URL, "-dana-key", danaKey,
+		"-merchant-id", "m", "-merchant-trans-id", "ORDER-1", "-amount", "15000", "-status", "closed")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "invalid notification answer")
+
+	assert.Equal(t, "1500000", gjson.Get(received, "request.body.orderAmount.value").String())
+	assert.Equal(t, "CLOSED", gjson.Get(received, "request.body.acquirementStatus").String())
+	assert.Equal(t, int64(200), gjson.Get(stdout, "statusCode").Int())
+	assert.Equal(t, "F", gjson.Get(stdout, "answer.response.body.resultInfo.resultStatus").String())
+	assert.True(t, gjson.Get(stdout, "problems.#").Int() > 0)
+}
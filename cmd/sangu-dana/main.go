@@ -0,0 +1,275 @@
+// Command sangu-dana calls DANA from the command line, signing requests with the merchant private key.
+//
+//	sangu-dana <command> [flags]
+//
+// Gateway commands read the credentials from a TOML file shaped like credential_test.toml.sample and print
+// DANA's response as JSON. With -dry-run they print the signed request, secrets masked, instead of sending it.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	dana "github.com/kitabisa/sangu-dana"
+)
+
+const (
+	DEFAULT_CONFIG_FILE      = "credential_test.toml"
+	DEFAULT_PRIVATE_KEY_FILE = "my_private.pem"
+	DEFAULT_PUBLIC_KEY_FILE  = "dana_public.pem"
+)
+
+// errDryRun stops a call once it is signed, before it is sent
+var errDryRun = errors.New("dry run")
+
+// command is a subcommand, args are the arguments following its name
+type command struct {
+	usage string
+	run   func(env *environment, args []string) error
+}
+
+var commands = map[string]command{}
+
+// environment is what commands read from and write to, so they can be run in tests
+type environment struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], &environment{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+func run(args []string, env *environment) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(env.stderr)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(env.stderr, "unknown command %q\n\n", args[0])
+		printUsage(env.stderr)
+		return 2
+	}
+
+	if err := cmd.run(env, args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 2
+		}
+
+		fmt.Fprintf(env.stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: sangu-dana <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-18s %s\n", name, commands[name].usage)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run sangu-dana <command> -h for the flags of a command")
+}
+
+// credentials is the TOML configuration file. Key files are relative to the configuration file.
+type credentials struct {
+	BaseUrl        string
+	Version        string
+	ClientId       string
+	ClientSecret   string
+	PrivateKeyFile string
+	PublicKeyFile  string
+}
+
+// gatewayFlags are the flags shared by the gateway commands
+type gatewayFlags struct {
+	config      string
+	accessToken string
+	dryRun      bool
+	verbose     bool
+}
+
+func newFlagSet(env *environment, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	return fs
+}
+
+func (g *gatewayFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.config, "config", DEFAULT_CONFIG_FILE, "TOML file with BaseUrl, Version, ClientId, ClientSecret, PrivateKeyFile and PublicKeyFile")
+	fs.StringVar(&g.accessToken, "access-token", "", "user access token")
+	fs.BoolVar(&g.dryRun, "dry-run", false, "print the signed request instead of sending it")
+	fs.BoolVar(&g.verbose, "v", false, "log requests and responses to stderr, with secrets redacted")
+}
+
+// gateway builds the gateway described by the configuration file. A dry run gateway prints the signed
+// request to env.stdout and fails every call with errDryRun.
+func (g *gatewayFlags) gateway(env *environment) (*dana.CoreGateway, error) {
+	data, err := ioutil.ReadFile(g.config)
+	if err != nil {
+		return nil, err
+	}
+
+	var cred credentials
+	if _, err = toml.Decode(string(data), &cred); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", g.config, err)
+	}
+
+	client := dana.NewClient()
+	client.BaseUrl = cred.BaseUrl
+	client.Version = cred.Version
+	client.ClientId = cred.ClientId
+	client.ClientSecret = cred.ClientSecret
+	client.LogLevel = 0
+	if g.verbose {
+		client.LogLevel = 3
+		client.Logger = dana.NewLogger(dana.LogOption{Format: "text", Level: dana.LOG_LEVEL_DEBUG, Pretty: true, Output: env.stderr})
+	}
+
+	dir := filepath.Dir(g.config)
+	client.PrivateKey, err = readKey(dir, cred.PrivateKeyFile, DEFAULT_PRIVATE_KEY_FILE)
+	if err != nil {
+		return nil, err
+	}
+
+	// the public key is only needed to verify responses
+	if !g.dryRun {
+		client.PublicKey, err = readKey(dir, cred.PublicKeyFile, DEFAULT_PUBLIC_KEY_FILE)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if g.dryRun {
+		client.Interceptors = append(client.Interceptors, dana.Interceptor{
+			BeforeSend: func(exchange *dana.Exchange) error {
+				if err := printRequest(env.stdout, exchange); err != nil {
+					return err
+				}
+				return errDryRun
+			},
+		})
+	}
+
+	return &dana.CoreGateway{Client: client}, nil
+}
+
+func readKey(dir, file, defaultFile string) ([]byte, error) {
+	if file == "" {
+		file = defaultFile
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+
+	return ioutil.ReadFile(file)
+}
+
+// printRequest prints the method, URL, headers and signed body of the request in exchange, with credentials
+// and signatures masked as in the logs
+func printRequest(w io.Writer, exchange *dana.Exchange) error {
+	redactor := dana.NewRedactor()
+
+	redacted := redactor.Request(&http.Request{Header: exchange.Request.Header.Clone()})
+	headers := map[string]string{}
+	for name := range redacted.Header {
+		headers[name] = redacted.Header.Get(name)
+	}
+
+	envelope := redactor.JSON(exchange.Envelope)
+	var body interface{} = string(envelope)
+	if json.Valid(envelope) {
+		body = json.RawMessage(envelope)
+	}
+
+	return printJSON(w, map[string]interface{}{
+		"method":  exchange.Request.Method,
+		"url":     exchange.Request.URL.String(),
+		"headers": headers,
+		"body":    body,
+	})
+}
+
+// finish prints DANA's response, a dry run error means the request was printed and is not an error
+func finish(env *environment, raw []byte, res interface{}, err error) error {
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+
+	// DANA's answer is printed even when it failed verification, to help understand why
+	if len(raw) > 0 {
+		var out bytes.Buffer
+		if json.Indent(&out, raw, "", "  ") == nil {
+			out.WriteByte('\n')
+			env.stdout.Write(out.Bytes())
+		} else {
+			fmt.Fprintln(env.stdout, string(raw))
+		}
+	} else if err == nil {
+		if printErr := printJSON(env.stdout, res); printErr != nil {
+			return printErr
+		}
+	}
+
+	return err
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// readBody decodes the JSON request body in file into v, "-" reads it from stdin
+func readBody(env *environment, file string, v interface{}) error {
+	data, err := readInput(env, file, "-body")
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid body %s: %v", file, err)
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+// newTestConfig writes a configuration and a private key into a temporary directory
+func newTestConfig(t *testing.T) (dir string, config string) {
+	dir, err := ioutil.TempDir("", "sangu-dana")
+	require.NoError(t, err)
+
+	privateKey, _, err := dana.GenerateKeyPair(dana.DEFAULT_KEY_BITS)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "merchant.pem"), privateKey, 0600))
+
+	config = filepath.Join(dir, "credential.toml")
+	require.NoError(t, ioutil.WriteFile(config, []byte(`BaseUrl = "https://dana.invalid"
+Version = "2.0"
+ClientId = "client-id"
+ClientSecret = "client-secret"
+PrivateKeyFile = "merchant.pem"
+`), 0600))
+
+	return dir, config
+}
+
+func runCommand(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(args, &environment{stdin: strings.NewReader(""), stdout: &out, stderr: &errOut})
+	return code, out.String(), errOut.String()
+}
+
+func TestQueryDryRun(t *testing.T) {
+	dir, config := newTestConfig(t)
+	defer os.RemoveAll(dir)
+
+	code, stdout, stderr := runCommand("query", "-config", config, "-dry-run", "-merchant-id", "m", "-acquirement-id", "acq-1")
+	require.Equal(t, 0, code, stderr)
+
+	assert.Equal(t, "POST", gjson.Get(stdout, "method").String())
+	assert.Equal(t, "https://dana.invalid/"+dana.QUERY_PATH, gjson.Get(stdout, "url").String())
+	assert.Equal(t, dana.FUNCTION_QUERY_ORDER, gjson.Get(stdout, "body.request.head.function").String())
+	assert.Equal(t, "acq-1", gjson.Get(stdout, "body.request.body.acquirementId").String())
+	assert.Equal(t, dana.REDACTED_MASK, gjson.Get(stdout, "body.signature").String())
+	assert.Equal(t, dana.REDACTED_MASK, gjson.Get(stdout, "body.request.head.clientSecret").String())
+	assert.NotContains(t, stdout, "client-secret")
+}
+
+func TestOrderDryRunReadsBody(t *testing.T) {
+	dir, config := newTestConfig(t)
+	defer os.RemoveAll(dir)
+
+	body := filepath.Join(dir, "order.json")
+	require.NoError(t, ioutil.WriteFile(body, []byte(`{
+		"merchantId": "m",
+		"productCode": "p",
+		"order": {"orderTitle": "Donation", "merchantTransId": "ORDER-1", "orderAmount": {"currency": "IDR", "value": "15000"}},
+		"envInfo": {"sourcePlatform": "IPG", "terminalType": "SYSTEM", "orderTerminalType": "SYSTEM"}
+	}`), 0600))
+
+	code, stdout, stderr := runCommand("order", "-config", config, "-dry-run", "-body", body)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "1500000", gjson.Get(stdout, "body.request.body.order.orderAmount.value").String())
+
+	code, _, stderr = runCommand("order", "-config", config, "-dry-run")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-body is required")
+}
+
+func TestInquiryUserInfoDryRunSignsHeaders(t *testing.T) {
+	dir, config := newTestConfig(t)
+	defer os.RemoveAll(dir)
+
+	code, stdout, stderr := runCommand("inquiry-user-info", "-config", config, "-dry-run", "-access-token", "tok")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, dana.REDACTED_MASK, gjson.Get(stdout, "headers.Signature").String())
+	assert.Equal(t, dana.REDACTED_MASK, gjson.Get(stdout, "body.accessToken").String())
+	assert.NotContains(t, stdout, `"tok"`)
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := runCommand()
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "apply-token")
+
+	code, _, stderr = runCommand("pay")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "pay"`)
+
+	code, _, stderr = runCommand("query", "-config", "missing.toml", "-merchant-id", "m", "-acquirement-id", "a")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "missing.toml")
+}
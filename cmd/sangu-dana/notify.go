@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strconv"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/kitabisa/sangu-dana/simulator"
+)
+
+func init() {
+	commands["notify"] = command{usage: "send a DANA signed finish payment notification to a merchant endpoint", run: runNotify}
+}
+
+func runNotify(env *environment, args []string) error {
+	fs := newFlagSet(env, "notify")
+	url := fs.String("url", "", "merchant notification endpoint (required)")
+	danaKey := fs.String("dana-key", "", "test DANA private key signing the notification, the endpoint must trust its public key (required)")
+	merchantPublicKey := fs.String("merchant-public-key", "", "merchant public key verifying the answer, empty skips the verification")
+	clientID := fs.String("client-id", "", "client id in the notification head")
+	merchantID := fs.String("merchant-id", "", "merchant id (required)")
+	acquirementID := fs.String("acquirement-id", "", "DANA acquirement id")
+	merchantTransID := fs.String("merchant-trans-id", "", "merchant transaction id (required)")
+	amount := fs.Int64("amount", 0, "order amount in rupiah")
+	status := fs.String("status", string(dana.ACQUIREMENT_STATUS_SUCCESS), "acquirement status")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *url == "" || *danaKey == "" || *merchantID == "" || *merchantTransID == "" {
+		return errors.New("-url, -dana-key, -merchant-id and -merchant-trans-id are required")
+	}
+
+	acquirementStatus, err := dana.ParseAcquirementStatus(*status)
+	if err != nil {
+		return err
+	}
+
+	notifier := &simulator.PayFinishNotifier{URL: *url, ClientID: *clientID}
+	if notifier.DanaPrivateKey, err = ioutil.ReadFile(*danaKey); err != nil {
+		return err
+	}
+	if *merchantPublicKey != "" {
+		if notifier.MerchantPublicKey, err = ioutil.ReadFile(*merchantPublicKey); err != nil {
+			return err
+		}
+	}
+
+	// amounts are sent in minor units, Rp 15.000 is 1500000
+	orderAmount := dana.Amount{Currency: dana.CURRENCY_IDR, Value: strconv.FormatInt(*amount, 10) + "00"}
+	body := simulator.NewPayFinishBody(*merchantID, *acquirementID, *merchantTransID, orderAmount, acquirementStatus)
+
+	res, err := notifier.Send(context.Background(), body)
+
+	var answer interface{} = string(res.ResponseBody)
+	if len(res.ResponseBody) > 0 && res.Response.Signature != "" {
+		answer = res.Response
+	}
+
+	if printErr := printJSON(env.stdout, map[string]interface{}{
+		"notification": res.Request,
+		"statusCode":   res.StatusCode,
+		"answer":       answer,
+		"problems":     res.Problems,
+	}); printErr != nil {
+		return printErr
+	}
+
+	return err
+}
@@ -6,36 +6,56 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"log"
+
+	"github.com/google/uuid"
 )
 
 const (
 	TYPE_ORDER      = "ORDER"
 	TYPE_PAY_NOTIFY = "PAY_NOTIFY"
+
+	TRANSFER_STATUS_PROCESSING = "PROCESSING"
+	TRANSFER_STATUS_SUCCESS    = "SUCCESS"
+	TRANSFER_STATUS_FAILED     = "FAILED"
 )
 
-func generateSignature(req interface{}, privateKey []byte) (sig string, err error) {
-	signer, err := parsePrivateKey(privateKey)
+// ensureRequestID fills an empty request ID with a random UUID. The ID is written back to the caller's
+// request so that a retry is recognised by DANA as the same request.
+func ensureRequestID(requestID *string) error {
+	if *requestID != "" {
+		return nil
+	}
+
+	id, err := uuid.NewRandom()
 	if err != nil {
-		err = fmt.Errorf("signer is damaged: %v", err)
-		return
+		return fmt.Errorf("failed to generate request id: %v", err)
+	}
+
+	*requestID = id.String()
+	return nil
+}
+
+// toDanaAmount converts an amount in whole rupiah into the minor unit representation DANA expects.
+func toDanaAmount(amount Amount) Amount {
+	if amount.Currency == "" {
+		amount.Currency = CURRENCY_IDR
 	}
+	amount.Value = fmt.Sprintf("%v00", amount.Value)
+	return amount
+}
+
+func generateSignature(req interface{}, privateKey []byte) (sig string, err error) {
 	plan, err := json.Marshal(req)
 	if err != nil {
 		err = fmt.Errorf("failed to marshal request: %v", err)
 		return
 	}
-	signed, err := signer.Sign(plan)
-	if err != nil {
-		err = fmt.Errorf("could not sign request: %v", err)
-	}
-	sig = base64.StdEncoding.EncodeToString(signed)
-	return
+
+	return SignPayload(plan, privateKey)
 }
 
 // parsePrivateKey parses a PEM encoded private key.
@@ -60,13 +80,7 @@ func parsePrivateKey(pemBytes []byte) (Signer, error) {
 }
 
 func verifySignature(data string, sig string, publicKey []byte) error {
-	parser, perr := parsePublicKey(publicKey)
-	if perr != nil {
-		log.Printf("could load public key: %v", perr)
-	}
-
-	ds, _ := base64.StdEncoding.DecodeString(sig)
-	return parser.Unsign([]byte(data), ds)
+	return VerifyPayload([]byte(data), sig, publicKey)
 }
 
 // parsePublicKey parses a PEM encoded private key.
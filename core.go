@@ -2,17 +2,13 @@ package dana
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"strings"
-	"time"
 
 	"github.com/tidwall/gjson"
-
-	"github.com/google/uuid"
-	"github.com/mitchellh/mapstructure"
 )
 
 const (
@@ -24,6 +20,9 @@ const (
 	DANA_TIME_LAYOUT        = "2006-01-02T15:04:05-07:00"
 	CURRENCY_IDR            = "IDR"
 	INQUIRY_USER_INFO_PATH  = "v1/customers/user/inquiryUserInfoByAccessToken.htm"
+	TRANSFER_INQUIRY_PATH   = "dana/disbursement/transfer/inquiry.htm"
+	TRANSFER_PATH           = "dana/disbursement/transfer/transfer.htm"
+	TRANSFER_QUERY_PATH     = "dana/disbursement/transfer/query.htm"
 
 	FUNCTION_CREATE_ORDER       = "dana.acquiring.order.createOrder"
 	FUNCTION_QUERY_ORDER        = "dana.acquiring.order.query"
@@ -31,93 +30,73 @@ const (
 	FUNCTION_APPLY_ACCESS_TOKEN = "dana.oauth.auth.applyToken"
 	FUNCTION_USER_PROFILE       = "dana.member.query.queryUserProfile"
 	FUNCTION_INQUIRY_USER_INFO  = "customers.openapi.user.inquiryUserInfoByAccessToken"
+	FUNCTION_TRANSFER_INQUIRY   = "dana.disbursement.transfer.inquiry"
+	FUNCTION_TRANSFER           = "dana.disbursement.transfer.transfer"
+	FUNCTION_TRANSFER_QUERY     = "dana.disbursement.transfer.query"
+	FUNCTION_FINISH_NOTIFY      = "dana.acquiring.order.finishNotify"
 )
 
 // CoreGateway struct
 type CoreGateway struct {
 	Client Client
+
+	ctx context.Context
 }
 
 // Call : base method to call Core API
 func (gateway *CoreGateway) Call(method, path string, header map[string]string, body io.Reader, v interface{}) error {
+	return gateway.CallContext(context.Background(), method, path, header, body, v)
+}
+
+// CallContext : same as Call, sending the request with ctx
+func (gateway *CoreGateway) CallContext(ctx context.Context, method, path string, header map[string]string, body io.Reader, v interface{}) error {
 	if !strings.HasPrefix(path, "/") {
 		path = "/" + path
 	}
 
 	path = gateway.Client.BaseUrl + path
 
-	return gateway.Client.Call(method, path, header, body, v)
+	return gateway.Client.CallContext(ctx, method, path, header, body, v)
 }
 
-func (gateway *CoreGateway) Order(reqBody *OrderRequestData, accessToken string) (res ResponseBody, err error) {
-	reqBody.Order.OrderAmount.Value = fmt.Sprintf("%v00", reqBody.Order.OrderAmount.Value)
+// Order : create an order. The order amount is given in rupiah and sent with the two decimal digits DANA
+// expects, reqBody is not modified so a retry can send it again.
+func (gateway *CoreGateway) Order(reqBody *OrderRequestData, accessToken string) (res OrderResponse, err error) {
+	ctx, span := gateway.startSpan("Order")
+	defer func() { endSpan(span, err) }()
 
-	res, err = gateway.requestToDana(reqBody, accessToken, FUNCTION_CREATE_ORDER, ORDER_PATH)
-	if err != nil {
-		return
-	}
-
-	var orderResponseData OrderResponseData
-	err = mapstructure.Decode(res.Response.Body, &orderResponseData)
-	if err != nil {
-		return
-	}
-
-	res.Response.Body = orderResponseData
+	body := *reqBody
+	body.Order.OrderAmount = toDanaAmount(reqBody.Order.OrderAmount)
 
+	err = gateway.requestToDana(ctx, &body, accessToken, FUNCTION_CREATE_ORDER, ORDER_PATH, &res)
 	return
 }
 
-func (gateway *CoreGateway) OrderDetail(reqBody *OrderDetailRequestData, accessToken string) (res ResponseBody, err error) {
-	res, err = gateway.requestToDana(reqBody, accessToken, FUNCTION_QUERY_ORDER, QUERY_PATH)
-	if err != nil {
-		return
-	}
-
-	var orderDetailData OrderDetailData
-	err = mapstructure.Decode(res.Response.Body, &orderDetailData)
-	if err != nil {
-		return
-	}
-
-	res.Response.Body = orderDetailData
+func (gateway *CoreGateway) OrderDetail(reqBody *OrderDetailRequestData, accessToken string) (res OrderDetailResponse, err error) {
+	ctx, span := gateway.startSpan("OrderDetail")
+	defer func() { endSpan(span, err) }()
 
+	err = gateway.requestToDana(ctx, reqBody, accessToken, FUNCTION_QUERY_ORDER, QUERY_PATH, &res)
 	return
 }
 
-func (gateway *CoreGateway) ApplyAccessToken(reqBody *RequestApplyAccessToken) (res ResponseBody, err error) {
-	res, err = gateway.requestToDana(reqBody, "", FUNCTION_APPLY_ACCESS_TOKEN, APPLY_ACCESS_TOKEN_PATH)
-	if err != nil {
-		return
-	}
-
-	var applyAccessToken ApplyAccessToken
-	err = mapstructure.Decode(res.Response.Body, &applyAccessToken)
-	if err != nil {
-		return
-	}
-
-	res.Response.Body = applyAccessToken
+func (gateway *CoreGateway) ApplyAccessToken(reqBody *RequestApplyAccessToken) (res ApplyAccessTokenResponse, err error) {
+	ctx, span := gateway.startSpan("ApplyAccessToken")
+	defer func() { endSpan(span, err) }()
 
+	err = gateway.requestToDana(ctx, reqBody, "", FUNCTION_APPLY_ACCESS_TOKEN, APPLY_ACCESS_TOKEN_PATH, &res)
 	return
 }
 
-func (gateway *CoreGateway) Refund(reqBody *RefundRequestData, accessToken string) (res ResponseBody, err error) {
-	reqBody.RefundAmount.Value = fmt.Sprintf("%v00", reqBody.RefundAmount.Value)
-
-	res, err = gateway.requestToDana(reqBody, accessToken, FUNCTION_REFUND, REFUND_PATH)
-	if err != nil {
-		return
-	}
-
-	var RefundResponseData RefundResponseData
-	err = mapstructure.Decode(res.Response.Body, &RefundResponseData)
-	if err != nil {
-		return
-	}
+// Refund : refund an order. The refund amount is given in rupiah, reqBody is not modified so a retry can send it again.
+func (gateway *CoreGateway) Refund(reqBody *RefundRequestData, accessToken string) (res RefundResponse, err error) {
+	ctx, span := gateway.startSpan("Refund")
+	defer func() { endSpan(span, err) }()
 
-	res.Response.Body = RefundResponseData
+	body := *reqBody
+	body.RefundAmount = toDanaAmount(reqBody.RefundAmount)
 
+	err = gateway.requestToDana(ctx, &body, accessToken, FUNCTION_REFUND, REFUND_PATH, &res)
 	return
 }
 
@@ -134,78 +113,104 @@ func (gateway *CoreGateway) GenerateSignature(req interface{}) (signature string
 func (gateway *CoreGateway) VerifySignature(res []byte, signature string) (err error) {
 	response := gjson.Get(string(res), "request")
 	err = verifySignature(response.String(), signature, gateway.Client.PublicKey)
+	gateway.Client.observeWebhook(gjson.Get(string(res), "request.head.function").String(), err)
 	if err != nil {
 		err = fmt.Errorf("could not verify request: %v", err)
 	}
 	return
 }
 
-func (gateway *CoreGateway) UserProfile(reqBody *UserProfileRequestData, accessToken string) (res ResponseBody, err error) {
-	res, err = gateway.requestToDana(reqBody, accessToken, FUNCTION_USER_PROFILE, USER_PROFILE_PATH)
-	if err != nil {
-		return
-	}
+func (gateway *CoreGateway) UserProfile(reqBody *UserProfileRequestData, accessToken string) (res UserProfileResponse, err error) {
+	ctx, span := gateway.startSpan("UserProfile")
+	defer func() { endSpan(span, err) }()
 
-	var userProfileResponseData UserProfileResponseData
-	err = mapstructure.Decode(res.Response.Body, &userProfileResponseData)
-	if err != nil {
-		return
-	}
+	err = gateway.requestToDana(ctx, reqBody, accessToken, FUNCTION_USER_PROFILE, USER_PROFILE_PATH, &res)
+	return
+}
 
-	res.Response.Body = userProfileResponseData
+func (gateway *CoreGateway) InquiryUserInfo(reqBody *InquiryUserInfoRequest, accessToken string) (res InquiryUserInfoResponse, err error) {
+	ctx, span := gateway.startSpan("InquiryUserInfo")
+	defer func() { endSpan(span, err) }()
 
+	err = gateway.requestToDanaV1(ctx, reqBody, accessToken, FUNCTION_INQUIRY_USER_INFO, INQUIRY_USER_INFO_PATH, &res)
 	return
 }
 
-func (gateway *CoreGateway) InquiryUserInfo(reqBody *InquiryUserInfoRequest, accessToken string) (res InquiryUserInfoResponse, err error) {
-	var response interface{}
-	response, err = gateway.requestToDanaV1(reqBody, accessToken, FUNCTION_INQUIRY_USER_INFO, INQUIRY_USER_INFO_PATH)
-	if err != nil {
-		return
-	}
+// TransferInquiry : check whether a disbursement to the given customer can be made and how much it will cost.
+// An empty RequestID is filled in, so the same reqBody can be sent again to TransferInquiry or Transfer.
+func (gateway *CoreGateway) TransferInquiry(reqBody *TransferInquiryRequestData) (res TransferInquiryResponse, err error) {
+	ctx, span := gateway.startSpan("TransferInquiry")
+	defer func() { endSpan(span, err) }()
 
-	var responseByte []byte
-	responseByte, err = json.Marshal(response)
+	err = ensureRequestID(&reqBody.RequestID)
 	if err != nil {
 		return
 	}
 
-	err = json.Unmarshal(responseByte, &res)
+	body := *reqBody
+	body.Amount = toDanaAmount(reqBody.Amount)
+
+	err = gateway.requestToDana(ctx, &body, "", FUNCTION_TRANSFER_INQUIRY, TRANSFER_INQUIRY_PATH, &res)
+	return
+}
+
+// Transfer : move money from the merchant account to the customer's DANA balance.
+// DANA treats RequestID as the idempotency key, so a retry must reuse the same reqBody (or RequestID)
+// to avoid paying out twice. An empty RequestID is filled in before the request is sent.
+func (gateway *CoreGateway) Transfer(reqBody *TransferRequestData) (res TransferResponse, err error) {
+	ctx, span := gateway.startSpan("Transfer")
+	defer func() { endSpan(span, err) }()
+
+	err = ensureRequestID(&reqBody.RequestID)
 	if err != nil {
 		return
 	}
 
+	body := *reqBody
+	body.Amount = toDanaAmount(reqBody.Amount)
+
+	err = gateway.requestToDana(ctx, &body, "", FUNCTION_TRANSFER, TRANSFER_PATH, &res)
 	return
 }
 
-func (gateway *CoreGateway) requestToDana(reqBody interface{}, accessToken string, headerFunction string, path string) (res ResponseBody, err error) {
-	now := time.Now()
+// TransferQuery : query the status of a disbursement previously sent through Transfer
+func (gateway *CoreGateway) TransferQuery(reqBody *TransferQueryRequestData) (res TransferQueryResponse, err error) {
+	ctx, span := gateway.startSpan("TransferQuery")
+	defer func() { endSpan(span, err) }()
+
+	err = gateway.requestToDana(ctx, reqBody, "", FUNCTION_TRANSFER_QUERY, TRANSFER_QUERY_PATH, &res)
+	return
+}
+
+// requestToDana sends reqBody in a signed envelope and decodes DANA's response into res
+func (gateway *CoreGateway) requestToDana(ctx context.Context, reqBody interface{}, accessToken string, headerFunction string, path string, res interface{}) (err error) {
+	now := gateway.Client.now()
 
 	head := RequestHeader{}
 	head.Version = gateway.Client.Version
 	head.Function = headerFunction
 	head.ClientID = gateway.Client.ClientId
-	head.ReqTime = now.Format(DANA_TIME_LAYOUT)
+	head.ReqTime = DanaTime{Time: now}.String()
 	head.ClientSecret = gateway.Client.ClientSecret
 
 	if accessToken != "" {
 		head.AccessToken = accessToken
 	}
 
-	var id uuid.UUID
-	id, err = uuid.NewUUID()
+	head.ReqMsgID, err = gateway.Client.newReqMsgID(ctx)
 	if err != nil {
-		return res, err
+		err = fmt.Errorf("failed to generate reqMsgId: %v", err)
+		return
 	}
 
-	head.ReqMsgID = id.String()
-
 	req := Request{
 		Head: head,
 		Body: reqBody,
 	}
 
+	_, signSpan := gateway.Client.startSpan(ctx, SPAN_SIGN)
 	sig, err := generateSignature(req, gateway.Client.PrivateKey)
+	endSpan(signSpan, err)
 	if err != nil {
 		err = fmt.Errorf("failed to generate signature: %v", err)
 		return
@@ -220,14 +225,18 @@ func (gateway *CoreGateway) requestToDana(reqBody interface{}, accessToken strin
 		return
 	}
 
-	log.Println("Dana request: ", string(reqJson))
+	gateway.Client.logDebug(ctx, "Dana request: %s", gateway.Client.Redactor.JSON(reqJson))
 	requestReader := bytes.NewBuffer(reqJson)
 
 	headers := map[string]string{
 		"Content-Type": "application/json",
 	}
 
-	err = gateway.Call("POST", path, headers, requestReader, &res)
+	summary := &callSummary{Function: headerFunction, ReqMsgID: head.ReqMsgID}
+	summary.setIdentifiers([]byte(gjson.GetBytes(reqJson, "request.body").Raw))
+
+	err = gateway.CallContext(withCallSummary(ctx, summary), "POST", path, headers, requestReader, res)
+	setResultAttributes(ctx, summary)
 	if err != nil {
 		return
 	}
@@ -235,37 +244,38 @@ func (gateway *CoreGateway) requestToDana(reqBody interface{}, accessToken strin
 	return
 }
 
-func (gateway *CoreGateway) requestToDanaV1(reqBody interface{}, accessToken string, headerFunction string, path string) (res interface{}, err error) {
-	now := time.Now()
+// requestToDanaV1 sends reqBody as it is, with the signature in the headers, and decodes DANA's response into res
+func (gateway *CoreGateway) requestToDanaV1(ctx context.Context, reqBody interface{}, accessToken string, headerFunction string, path string, res interface{}) (err error) {
+	now := gateway.Client.now()
 
 	head := RequestHeader{}
 	head.Version = gateway.Client.Version
 	head.Function = headerFunction
 	head.ClientID = gateway.Client.ClientId
-	head.ReqTime = now.Format(DANA_TIME_LAYOUT)
+	head.ReqTime = DanaTime{Time: now}.String()
 	head.ClientSecret = gateway.Client.ClientSecret
 
 	if accessToken != "" {
 		head.AccessToken = accessToken
 	}
 
-	var id uuid.UUID
-	id, err = uuid.NewUUID()
+	head.ReqMsgID, err = gateway.Client.newReqMsgID(ctx)
 	if err != nil {
+		err = fmt.Errorf("failed to generate reqMsgId: %v", err)
 		return
 	}
 
-	head.ReqMsgID = id.String()
-
 	req := Request{
 		Head: head,
 		Body: reqBody,
 	}
 
+	_, signSpan := gateway.Client.startSpan(ctx, SPAN_SIGN)
 	sig, err := generateSignature(req, gateway.Client.PrivateKey)
+	endSpan(signSpan, err)
 	if err != nil {
 		err = fmt.Errorf("failed to generate signature: %v", err)
-		log.Println("generateSignature Failed: ", err)
+		gateway.Client.logError(ctx, "generateSignature failed: %v", err)
 		return
 	}
 
@@ -274,21 +284,20 @@ func (gateway *CoreGateway) requestToDanaV1(reqBody interface{}, accessToken str
 		return
 	}
 
-	log.Println("Dana request: ", string(reqJson))
+	gateway.Client.logDebug(ctx, "Dana request: %s", gateway.Client.Redactor.JSON(reqJson))
 
 	headers := map[string]string{
 		"Content-Type": "application/json",
 		"Client-Id":    gateway.Client.ClientId,
-		"Request-Time": now.Format(DANA_TIME_LAYOUT),
+		"Request-Time": DanaTime{Time: now}.String(),
 		"Signature":    sig,
 	}
 
-	bodyReq := bytes.NewBuffer(reqJson)
-	err = gateway.Call("POST", path, headers, bodyReq, &res)
-	if err != nil {
-		log.Println("Failed call dana endpoint: ", err)
-		return
-	}
+	summary := &callSummary{Function: headerFunction, ReqMsgID: head.ReqMsgID}
+	summary.setIdentifiers(reqJson)
 
+	bodyReq := bytes.NewBuffer(reqJson)
+	err = gateway.CallContext(withCallSummary(ctx, summary), "POST", path, headers, bodyReq, res)
+	setResultAttributes(ctx, summary)
 	return
 }
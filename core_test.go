@@ -0,0 +1,227 @@
+package dana
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransfer(t *testing.T) {
+	var received []TransferRequestData
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		assert.Equal(t, "/"+TRANSFER_PATH, path)
+		assert.Equal(t, FUNCTION_TRANSFER, req.Head.Function)
+
+		var body TransferRequestData
+		decodeBody(t, req.Body, &body)
+		received = append(received, body)
+
+		return TransferResponseData{
+			ResultInfo: ResultInfo{ResultStatus: "S", ResultCodeID: "00000000"},
+			RequestID:  body.RequestID,
+			TransferID: "20201001111212800100166",
+			Amount:     body.Amount,
+		}
+	})
+	defer fake.Close()
+	gateway := fake.gateway()
+
+	reqBody := &TransferRequestData{
+		MerchantID:     "216620000000000000000",
+		CustomerNumber: "6281234567890",
+		Amount:         Amount{Value: "15000"},
+	}
+
+	res, err := gateway.Transfer(reqBody)
+	require.NoError(t, err)
+	require.NotEmpty(t, reqBody.RequestID)
+	assert.Equal(t, "15000", reqBody.Amount.Value, "request amount must not be modified")
+
+	data := res.Response.Body
+	assert.Equal(t, "S", data.ResultInfo.ResultStatus)
+	assert.Equal(t, reqBody.RequestID, data.RequestID)
+	assert.Equal(t, "20201001111212800100166", data.TransferID)
+
+	// a retry reuses the request id and sends the same amount
+	_, err = gateway.Transfer(reqBody)
+	require.NoError(t, err)
+
+	require.Len(t, received, 2)
+	assert.Equal(t, received[0].RequestID, received[1].RequestID)
+	assert.Equal(t, Amount{Currency: CURRENCY_IDR, Value: "1500000"}, received[0].Amount)
+	assert.Equal(t, received[0].Amount, received[1].Amount)
+}
+
+func TestTransferInquiry(t *testing.T) {
+	var received TransferInquiryRequestData
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		assert.Equal(t, "/"+TRANSFER_INQUIRY_PATH, path)
+		assert.Equal(t, FUNCTION_TRANSFER_INQUIRY, req.Head.Function)
+
+		decodeBody(t, req.Body, &received)
+
+		return TransferInquiryResponseData{
+			ResultInfo:     ResultInfo{ResultStatus: "S", ResultCodeID: "00000000"},
+			RequestID:      received.RequestID,
+			CustomerNumber: received.CustomerNumber,
+			CustomerName:   "Budi",
+			Amount:         received.Amount,
+			FeeAmount:      Amount{Currency: CURRENCY_IDR, Value: "250000"},
+		}
+	})
+	defer fake.Close()
+	gateway := fake.gateway()
+
+	reqBody := &TransferInquiryRequestData{
+		MerchantID:     "216620000000000000000",
+		CustomerNumber: "6281234567890",
+		Amount:         Amount{Value: "15000"},
+	}
+
+	res, err := gateway.TransferInquiry(reqBody)
+	require.NoError(t, err)
+	require.NotEmpty(t, reqBody.RequestID)
+	assert.Equal(t, "15000", reqBody.Amount.Value, "request amount must not be modified")
+
+	assert.Equal(t, reqBody.RequestID, received.RequestID)
+	assert.Equal(t, "6281234567890", received.CustomerNumber)
+	assert.Equal(t, Amount{Currency: CURRENCY_IDR, Value: "1500000"}, received.Amount)
+
+	data := res.Response.Body
+	assert.Equal(t, "S", data.ResultInfo.ResultStatus)
+	assert.Equal(t, reqBody.RequestID, data.RequestID)
+	assert.Equal(t, "Budi", data.CustomerName)
+	assert.Equal(t, Amount{Currency: CURRENCY_IDR, Value: "250000"}, data.FeeAmount)
+}
+
+func TestOrderAndRefundDoNotModifyTheRequest(t *testing.T) {
+	var amounts []Amount
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		if req.Head.Function == FUNCTION_REFUND {
+			var body RefundRequestData
+			decodeBody(t, req.Body, &body)
+			amounts = append(amounts, body.RefundAmount)
+			return RefundResponseData{ResultInfo: ResultInfo{ResultStatus: "S"}}
+		}
+
+		var body OrderRequestData
+		decodeBody(t, req.Body, &body)
+		amounts = append(amounts, body.Order.OrderAmount)
+		return OrderResponseData{ResultInfo: ResultInfo{ResultStatus: "S"}}
+	})
+	defer fake.Close()
+	gateway := fake.gateway()
+
+	order := &OrderRequestData{MerchantID: "m", Order: Order{MerchantTransID: "ORDER-1", OrderAmount: Amount{Value: "15000"}}}
+	for i := 0; i < 2; i++ {
+		_, err := gateway.Order(order, "")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, Amount{Value: "15000"}, order.Order.OrderAmount, "request amount must not be modified")
+
+	refund := &RefundRequestData{RequestID: "refund-1", MerchantID: "m", RefundAmount: Amount{Currency: CURRENCY_IDR, Value: "5000"}}
+	for i := 0; i < 2; i++ {
+		_, err := gateway.Refund(refund, "")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "5000", refund.RefundAmount.Value, "request amount must not be modified")
+
+	assert.Equal(t, []Amount{
+		{Currency: CURRENCY_IDR, Value: "1500000"},
+		{Currency: CURRENCY_IDR, Value: "1500000"},
+		{Currency: CURRENCY_IDR, Value: "500000"},
+		{Currency: CURRENCY_IDR, Value: "500000"},
+	}, amounts)
+}
+
+func TestTransferQuery(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		assert.Equal(t, FUNCTION_TRANSFER_QUERY, req.Head.Function)
+
+		return TransferQueryResponseData{
+			ResultInfo:     ResultInfo{ResultStatus: "S"},
+			RequestID:      "req-1",
+			TransferStatus: TRANSFER_STATUS_SUCCESS,
+		}
+	})
+	defer fake.Close()
+	gateway := fake.gateway()
+
+	res, err := gateway.TransferQuery(&TransferQueryRequestData{MerchantID: "m", RequestID: "req-1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, TRANSFER_STATUS_SUCCESS, res.Response.Body.TransferStatus)
+	assert.Contains(t, string(res.Raw), `"transferStatus":"SUCCESS"`)
+}
+
+func TestOrderDetailDecodesNestedFields(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		return map[string]interface{}{
+			"resultInfo":    map[string]string{"resultStatus": "S", "resultCodeId": "00000000"},
+			"acquirementId": "acq-1",
+			"amountDetail": map[string]interface{}{
+				"orderAmount":  map[string]string{"currency": "IDR", "value": "1500000"},
+				"refundAmount": map[string]string{"currency": "IDR", "value": "500000"},
+			},
+			"statusDetail": map[string]interface{}{"acquirementStatus": "SUCCESS", "frozen": false},
+			"timeDetail":   map[string]interface{}{"paidTimes": []string{"2020-10-01T11:12:12+07:00"}},
+		}
+	})
+	defer fake.Close()
+	gateway := fake.gateway()
+
+	res, err := gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m", AcquirementID: "acq-1"}, "")
+	require.NoError(t, err)
+
+	body := res.Response.Body
+	assert.Equal(t, "acq-1", body.AcquirementID)
+	assert.Equal(t, "1500000", body.AmountDetail.OrderAmount.Value)
+	assert.Equal(t, "500000", body.AmountDetail.RefundAmount.Value)
+	assert.Equal(t, ACQUIREMENT_STATUS_SUCCESS, body.StatusDetail.AcquirementStatus)
+	require.Len(t, body.TimeDetail.PaidTimes, 1)
+	assert.Equal(t, "2020-10-01T11:12:12+07:00", body.TimeDetail.PaidTimes[0].String())
+	assert.True(t, body.TimeDetail.CancelledTime.IsZero())
+	assert.Equal(t, FUNCTION_QUERY_ORDER, res.Response.Head.Function)
+	assert.Contains(t, string(res.Raw), `"acquirementId":"acq-1"`)
+}
+
+func TestClockAndReqMsgID(t *testing.T) {
+	var heads []RequestHeader
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		heads = append(heads, req.Head)
+		return OrderDetailData{ResultInfo: ResultInfo{ResultStatus: "S"}}
+	})
+	defer fake.Close()
+	gateway := fake.gateway()
+
+	_, err := gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m"}, "")
+	require.NoError(t, err)
+
+	gateway.Client.Clock = func() time.Time { return time.Date(2020, 10, 1, 4, 12, 12, 0, time.UTC) }
+	gateway.Client.ReqMsgIDGenerator = func(ctx context.Context) (string, error) { return "generated-1", nil }
+
+	_, err = gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m"}, "")
+	require.NoError(t, err)
+
+	ctx := WithReqMsgID(context.Background(), "caller-request-1")
+	_, err = gateway.WithContext(ctx).OrderDetail(&OrderDetailRequestData{MerchantID: "m"}, "")
+	require.NoError(t, err)
+
+	gateway.Client.ReqMsgIDGenerator = func(ctx context.Context) (string, error) { return "", errors.New("no id") }
+	_, err = gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m"}, "")
+	assert.Error(t, err)
+
+	require.Len(t, heads, 3)
+	id, err := uuid.Parse(heads[0].ReqMsgID)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(4), id.Version())
+
+	assert.Equal(t, "2020-10-01T11:12:12+07:00", heads[1].ReqTime)
+	assert.Equal(t, "generated-1", heads[1].ReqMsgID)
+	assert.Equal(t, "caller-request-1", heads[2].ReqMsgID)
+}
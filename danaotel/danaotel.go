@@ -0,0 +1,76 @@
+// Package danaotel records the spans of sangu-dana gateway calls with OpenTelemetry.
+//
+//	client := dana.NewClient()
+//	client.Tracer = danaotel.NewTracer(nil) // uses the global TracerProvider
+//
+//	res, err := gateway.WithContext(ctx).Order(reqBody, accessToken)
+package danaotel
+
+import (
+	"context"
+	"fmt"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TRACER_NAME = "github.com/kitabisa/sangu-dana"
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns a dana.Tracer creating spans with provider, or with the global TracerProvider when provider is nil
+func NewTracer(provider trace.TracerProvider) dana.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return &tracer{
+		tracer: provider.Tracer(TRACER_NAME),
+	}
+}
+
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, dana.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(spanKind(name)))
+	return ctx, &otelSpan{span: span}
+}
+
+// the HTTP exchange is the only span leaving the process
+func spanKind(name string) trace.SpanKind {
+	if name == dana.SPAN_HTTP {
+		return trace.SpanKindClient
+	}
+	return trace.SpanKindInternal
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s *otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
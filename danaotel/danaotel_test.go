@@ -0,0 +1,101 @@
+package danaotel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newKeyPair(t *testing.T) (private []byte, public []byte) {
+	private, public, err := dana.GenerateKeyPair(dana.DEFAULT_KEY_BITS)
+	require.NoError(t, err)
+	return
+}
+
+// newGateway returns a gateway talking to a local server that answers every call with a signed order detail
+func newGateway(t *testing.T, provider *sdktrace.TracerProvider) (dana.CoreGateway, func()) {
+	merchantPrivate, _ := newKeyPair(t)
+	danaPrivate, danaPublic := newKeyPair(t)
+
+	signer := dana.CoreGateway{Client: dana.Client{PrivateKey: danaPrivate}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := dana.Response{
+			Head: dana.ResponseHeader{Function: dana.FUNCTION_QUERY_ORDER},
+			Body: dana.OrderDetailData{ResultInfo: dana.ResultInfo{ResultStatus: "S", ResultCodeID: "00000000"}},
+		}
+		signature, err := signer.GenerateSignature(response)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(dana.ResponseBody{Response: response, Signature: signature})
+	}))
+
+	client := dana.NewClient()
+	client.BaseUrl = server.URL
+	client.PrivateKey = merchantPrivate
+	client.PublicKey = danaPublic
+	client.LogLevel = 0
+	client.Tracer = NewTracer(provider)
+
+	return dana.CoreGateway{Client: client}, server.Close
+}
+
+func TestGatewaySpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	gateway, closeServer := newGateway(t, provider)
+	defer closeServer()
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "checkout")
+	_, err := gateway.WithContext(ctx).OrderDetail(&dana.OrderDetailRequestData{MerchantID: "m", MerchantTransID: "ORDER-1"}, "")
+	parent.End()
+	require.NoError(t, err)
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	require.Len(t, spans, 5)
+
+	method := spans["dana.OrderDetail"]
+	assert.Equal(t, parent.SpanContext().SpanID(), method.Parent.SpanID(), "the gateway span continues the caller's trace")
+	for _, name := range []string{dana.SPAN_SIGN, dana.SPAN_HTTP, dana.SPAN_VERIFY} {
+		assert.Equal(t, method.SpanContext.SpanID(), spans[name].Parent.SpanID(), name)
+	}
+
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range method.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	assert.Equal(t, dana.FUNCTION_QUERY_ORDER, attributes[dana.ATTRIBUTE_FUNCTION].AsString())
+	assert.Equal(t, "ORDER-1", attributes[dana.ATTRIBUTE_MERCHANT_TRANS_ID].AsString())
+	assert.Equal(t, "S", attributes[dana.ATTRIBUTE_RESULT_STATUS].AsString())
+	assert.Equal(t, "00000000", attributes[dana.ATTRIBUTE_RESULT_CODE_ID].AsString())
+}
+
+func TestGatewaySpanRecordsError(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	gateway, closeServer := newGateway(t, provider)
+	closeServer()
+
+	_, err := gateway.OrderDetail(&dana.OrderDetailRequestData{MerchantID: "m"}, "")
+	require.Error(t, err)
+
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "dana.OrderDetail" || span.Name == dana.SPAN_HTTP {
+			assert.Equal(t, codes.Error, span.Status.Code, span.Name)
+		}
+	}
+}
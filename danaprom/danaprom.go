@@ -0,0 +1,98 @@
+// Package danaprom exposes the sangu-dana client metrics to Prometheus.
+//
+//	metrics, err := danaprom.NewMetrics(prometheus.DefaultRegisterer)
+//	client := dana.NewClient()
+//	client.Metrics = metrics
+package danaprom
+
+import (
+	"strconv"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	NAMESPACE = "dana"
+
+	LABEL_FUNCTION      = "function"
+	LABEL_HTTP_STATUS   = "http_status"
+	LABEL_RESULT_STATUS = "result_status"
+	LABEL_RESULT_CODE   = "result_code"
+	LABEL_OUTCOME       = "outcome"
+	LABEL_KIND          = "kind"
+
+	OUTCOME_SUCCESS = "success"
+	OUTCOME_FAILURE = "failure"
+	OUTCOME_ERROR   = "error"
+
+	RESULT_STATUS_SUCCESS = "S"
+)
+
+// Metrics implements dana.Metrics with Prometheus collectors
+type Metrics struct {
+	calls             *prometheus.CounterVec
+	latency           *prometheus.HistogramVec
+	signatureFailures *prometheus.CounterVec
+	webhooks          *prometheus.CounterVec
+}
+
+// NewMetrics creates the collectors and registers them with registerer
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "calls_total",
+			Help:      "DANA calls by function, HTTP status, DANA result and outcome.",
+		}, []string{LABEL_FUNCTION, LABEL_HTTP_STATUS, LABEL_RESULT_STATUS, LABEL_RESULT_CODE, LABEL_OUTCOME}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Name:      "call_duration_seconds",
+			Help:      "Latency of DANA calls by function and outcome.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15},
+		}, []string{LABEL_FUNCTION, LABEL_OUTCOME}),
+		signatureFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "signature_verification_failures_total",
+			Help:      "DANA responses whose signature did not verify, by function.",
+		}, []string{LABEL_FUNCTION}),
+		webhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "webhooks_total",
+			Help:      "DANA notifications received, by kind and verification outcome.",
+		}, []string{LABEL_KIND, LABEL_OUTCOME}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.calls, m.latency, m.signatureFailures, m.webhooks} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ObserveCall counts the call and records its latency. The outcome is "error" when the call failed,
+// "failure" when DANA answered with a result status other than S, and "success" otherwise.
+func (m *Metrics) ObserveCall(call dana.CallMetric) {
+	outcome := OUTCOME_SUCCESS
+	switch {
+	case call.Err != nil:
+		outcome = OUTCOME_ERROR
+	case call.ResultStatus != "" && call.ResultStatus != RESULT_STATUS_SUCCESS:
+		outcome = OUTCOME_FAILURE
+	case call.HTTPStatus >= 300:
+		outcome = OUTCOME_FAILURE
+	}
+
+	m.calls.WithLabelValues(call.Function, strconv.Itoa(call.HTTPStatus), call.ResultStatus, call.ResultCodeID, outcome).Inc()
+	m.latency.WithLabelValues(call.Function, outcome).Observe(call.Latency.Seconds())
+}
+
+func (m *Metrics) SignatureVerificationFailed(function string) {
+	m.signatureFailures.WithLabelValues(function).Inc()
+}
+
+func (m *Metrics) WebhookReceived(kind string, outcome string) {
+	m.webhooks.WithLabelValues(kind, outcome).Inc()
+}
@@ -0,0 +1,37 @@
+package danaprom
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetrics(registry)
+	require.NoError(t, err)
+
+	var _ dana.Metrics = metrics
+
+	metrics.ObserveCall(dana.CallMetric{Function: dana.FUNCTION_CREATE_ORDER, HTTPStatus: 200, ResultStatus: "S", ResultCodeID: "00000000", Latency: 120 * time.Millisecond})
+	metrics.ObserveCall(dana.CallMetric{Function: dana.FUNCTION_CREATE_ORDER, HTTPStatus: 200, ResultStatus: "F", ResultCodeID: "00000004", Latency: 80 * time.Millisecond})
+	metrics.ObserveCall(dana.CallMetric{Function: dana.FUNCTION_REFUND, Err: errors.New("timeout"), Latency: 15 * time.Second})
+	metrics.SignatureVerificationFailed(dana.FUNCTION_REFUND)
+	metrics.WebhookReceived(dana.WEBHOOK_KIND_SNAP, dana.WEBHOOK_OUTCOME_VERIFIED)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.calls.WithLabelValues(dana.FUNCTION_CREATE_ORDER, "200", "S", "00000000", OUTCOME_SUCCESS)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.calls.WithLabelValues(dana.FUNCTION_CREATE_ORDER, "200", "F", "00000004", OUTCOME_FAILURE)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.calls.WithLabelValues(dana.FUNCTION_REFUND, "0", "", "", OUTCOME_ERROR)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.signatureFailures.WithLabelValues(dana.FUNCTION_REFUND)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.webhooks.WithLabelValues(dana.WEBHOOK_KIND_SNAP, dana.WEBHOOK_OUTCOME_VERIFIED)))
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.latency))
+
+	_, err = NewMetrics(registry)
+	assert.Error(t, err, "collectors cannot be registered twice")
+}
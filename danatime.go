@@ -0,0 +1,76 @@
+package dana
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// DanaTimeLocation is the location times are written in, DANA expects the Asia/Jakarta offset (+07:00)
+var DanaTimeLocation = time.FixedZone("WIB", 7*60*60)
+
+// DanaTime is a time written in JSON with DANA_TIME_LAYOUT, e.g. "2020-10-01T11:12:12+07:00".
+// The zero DanaTime is written as null, and null or "" are read as the zero DanaTime.
+type DanaTime struct {
+	time.Time
+}
+
+// NewDanaTime : wrap t for the optional time fields of requests
+func NewDanaTime(t time.Time) *DanaTime {
+	return &DanaTime{Time: t}
+}
+
+func (t DanaTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+func (t *DanaTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("dana time must be a string, got %s", data)
+	}
+
+	return t.UnmarshalText(data[1 : len(data)-1])
+}
+
+// MarshalText : format the time with DANA_TIME_LAYOUT, the zero DanaTime is written as an empty text.
+// It replaces the RFC 3339 MarshalText promoted from the embedded time.Time.
+func (t DanaTime) MarshalText() ([]byte, error) {
+	if t.IsZero() {
+		return []byte{}, nil
+	}
+
+	return []byte(t.String()), nil
+}
+
+func (t *DanaTime) UnmarshalText(data []byte) error {
+	if len(data) == 0 {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	value := string(data)
+	parsed, err := time.Parse(DANA_TIME_LAYOUT, value)
+	if err != nil {
+		// DANA sometimes adds fractional seconds
+		if parsed, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return fmt.Errorf("invalid dana time %q", value)
+		}
+	}
+
+	t.Time = parsed
+	return nil
+}
+
+// String : format the time with DANA_TIME_LAYOUT in DanaTimeLocation
+func (t DanaTime) String() string {
+	return t.In(DanaTimeLocation).Format(DANA_TIME_LAYOUT)
+}
@@ -0,0 +1,66 @@
+package dana
+
+import (
+	"encoding"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDanaTimeMarshalsInJakartaOffset(t *testing.T) {
+	paid := time.Date(2020, 10, 1, 4, 12, 12, 0, time.UTC)
+
+	data, err := json.Marshal(RefundRequestData{RefundAppliedTime: NewDanaTime(paid)})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"refundAppliedTime":"2020-10-01T11:12:12+07:00"`)
+
+	data, err = json.Marshal(Order{})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "createdTime")
+	assert.NotContains(t, string(data), "expiryTime")
+
+	data, err = json.Marshal(TimeDetail{})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"cancelledTime":null`)
+}
+
+func TestDanaTimeUnmarshal(t *testing.T) {
+	var finish RequestBodyPayFinish
+	err := json.Unmarshal([]byte(`{"finishedTime":"2020-10-01T11:12:12+07:00","createdTime":""}`), &finish)
+	require.NoError(t, err)
+	assert.True(t, finish.FinishedTime.Equal(time.Date(2020, 10, 1, 4, 12, 12, 0, time.UTC)))
+	assert.True(t, finish.CreatedTime.IsZero())
+
+	var detail TimeDetail
+	err = json.Unmarshal([]byte(`{"createdTime":"2020-10-01T11:12:12.123+07:00","cancelledTime":null}`), &detail)
+	require.NoError(t, err)
+	assert.Equal(t, 123*time.Millisecond, time.Duration(detail.CreatedTime.Nanosecond()))
+	assert.True(t, detail.CancelledTime.IsZero())
+
+	err = json.Unmarshal([]byte(`{"createdTime":"01/10/2020"}`), &detail)
+	assert.Error(t, err)
+
+	err = json.Unmarshal([]byte(`{"createdTime":1601525532}`), &detail)
+	assert.Error(t, err)
+}
+
+func TestDanaTimeText(t *testing.T) {
+	paid := DanaTime{Time: time.Date(2020, 10, 1, 4, 12, 12, 0, time.UTC)}
+
+	var marshaler encoding.TextMarshaler = paid
+	text, err := marshaler.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "2020-10-01T11:12:12+07:00", string(text))
+
+	var read DanaTime
+	require.NoError(t, read.UnmarshalText(text))
+	assert.True(t, read.Equal(paid.Time))
+
+	require.NoError(t, read.UnmarshalText([]byte{}))
+	assert.True(t, read.IsZero())
+
+	assert.Error(t, read.UnmarshalText([]byte("yesterday")))
+}
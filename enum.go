@@ -1,60 +1,217 @@
 package dana
 
-type PayMethodEnum int
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Enums hold DANA's names. The zero value is unset: it is written as an empty string and omitted by omitempty
+// fields. A name DANA sends that this version doesn't know is kept as is, so it is written back unchanged.
+
+type PayMethodEnum string
 
 const (
-	Balance PayMethodEnum = iota
-	Coupon
-	NetBanking
-	CreditCard
-	DebitCard
-	VirtualAccount
-	Otc
-	DirectDebitCreditCard
-	DirectDebitDebitCard
+	Balance               PayMethodEnum = "BALANCE"
+	Coupon                PayMethodEnum = "COUPON"
+	NetBanking            PayMethodEnum = "NET_BANKING"
+	CreditCard            PayMethodEnum = "CREDIT_CARD"
+	DebitCard             PayMethodEnum = "DEBIT_CARD"
+	VirtualAccount        PayMethodEnum = "VIRTUAL_ACCOUNT"
+	Otc                   PayMethodEnum = "OTC"
+	DirectDebitCreditCard PayMethodEnum = "DIRECT_DEBIT_CREDIT_CARD"
+	DirectDebitDebitCard  PayMethodEnum = "DIRECT_DEBIT_DEBIT_CARD"
 )
 
+var payMethods = []PayMethodEnum{Balance, Coupon, NetBanking, CreditCard, DebitCard, VirtualAccount, Otc, DirectDebitCreditCard, DirectDebitDebitCard}
+
 func (p PayMethodEnum) String() string {
-	return [...]string{
-		"BALANCE",
-		"COUPON",
-		"NET_BANKING",
-		"CREDIT_CARD",
-		"DEBIT_CARD",
-		"VIRTUAL_ACCOUNT",
-		"OTC",
-		"DIRECT_DEBIT_CREDIT_CARD",
-		"DIRECT_DEBIT_DEBIT_CARD"}[p]
+	return string(p)
+}
+
+// IsValid : whether p is one of the pay methods DANA documents
+func (p PayMethodEnum) IsValid() bool {
+	for _, method := range payMethods {
+		if p == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePayMethod : parse a pay method name such as "CREDIT_CARD", case insensitively
+func ParsePayMethod(s string) (PayMethodEnum, error) {
+	p := PayMethodEnum(normalizeEnum(s))
+	if !p.IsValid() {
+		return "", fmt.Errorf("unknown pay method %q", s)
+	}
+	return p, nil
+}
+
+func (p *PayMethodEnum) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalEnum(data, "pay method")
+	if err == nil {
+		*p = readPayMethod(value)
+	}
+	return err
+}
+
+// readPayMethod keeps a name this version doesn't know
+func readPayMethod(s string) PayMethodEnum {
+	if p, err := ParsePayMethod(s); err == nil {
+		return p
+	}
+	return PayMethodEnum(s)
 }
 
-type ActorTypeEnum int
+const PAY_METHODS_SEPARATOR = "^"
+
+// PayMethods is a list of pay methods, written the way DANA expects it: one string with the names separated by ^
+type PayMethods []PayMethodEnum
+
+func (p PayMethods) String() string {
+	names := make([]string, 0, len(p))
+	for _, method := range p {
+		names = append(names, method.String())
+	}
+
+	return strings.Join(names, PAY_METHODS_SEPARATOR)
+}
+
+func (p PayMethods) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+func (p *PayMethods) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("pay methods must be a string, got %s", data)
+	}
+
+	methods := PayMethods{}
+	if value != "" {
+		for _, name := range strings.Split(value, PAY_METHODS_SEPARATOR) {
+			methods = append(methods, readPayMethod(name))
+		}
+	}
+
+	*p = methods
+	return nil
+}
+
+type ActorTypeEnum string
 
 const (
-	User ActorTypeEnum = iota
-	Merchant
-	MerchantOperator
-	BackOffice
-	System
+	User             ActorTypeEnum = "USER"
+	Merchant         ActorTypeEnum = "MERCHANT"
+	MerchantOperator ActorTypeEnum = "MERCHANT_OPERATOR"
+	BackOffice       ActorTypeEnum = "BACK_OFFICE"
+	System           ActorTypeEnum = "SYSTEM"
 )
 
+var actorTypes = []ActorTypeEnum{User, Merchant, MerchantOperator, BackOffice, System}
+
 func (a ActorTypeEnum) String() string {
-	return [...]string{
-		"USER",
-		"MERCHANT",
-		"MERCHANT_OPERATOR",
-		"BACK_OFFICE",
-		"SYSTEM"}[a]
+	return string(a)
 }
 
-type RefundDestinationEnum int
+// IsValid : whether a is one of the actor types DANA documents
+func (a ActorTypeEnum) IsValid() bool {
+	for _, actorType := range actorTypes {
+		if a == actorType {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseActorType : parse an actor type name such as "MERCHANT", case insensitively
+func ParseActorType(s string) (ActorTypeEnum, error) {
+	a := ActorTypeEnum(normalizeEnum(s))
+	if !a.IsValid() {
+		return "", fmt.Errorf("unknown actor type %q", s)
+	}
+	return a, nil
+}
+
+func (a *ActorTypeEnum) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalEnum(data, "actor type")
+	if err == nil {
+		*a = readActorType(value)
+	}
+	return err
+}
+
+// readActorType keeps a name this version doesn't know
+func readActorType(s string) ActorTypeEnum {
+	if a, err := ParseActorType(s); err == nil {
+		return a
+	}
+	return ActorTypeEnum(s)
+}
+
+type RefundDestinationEnum string
 
 const (
-	ToBalance RefundDestinationEnum = iota
-	ToSource
+	ToBalance RefundDestinationEnum = "TO_BALANCE"
+	ToSource  RefundDestinationEnum = "TO_SOURCE"
 )
 
+var refundDestinations = []RefundDestinationEnum{ToBalance, ToSource}
+
 func (r RefundDestinationEnum) String() string {
-	return [...]string{
-		"TO_BALANCE",
-		"TO_SOURCE"}[r]
+	return string(r)
+}
+
+// IsValid : whether r is one of the refund destinations DANA documents
+func (r RefundDestinationEnum) IsValid() bool {
+	for _, destination := range refundDestinations {
+		if r == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRefundDestination : parse a refund destination name such as "TO_SOURCE", case insensitively
+func ParseRefundDestination(s string) (RefundDestinationEnum, error) {
+	r := RefundDestinationEnum(normalizeEnum(s))
+	if !r.IsValid() {
+		return "", fmt.Errorf("unknown refund destination %q", s)
+	}
+	return r, nil
+}
+
+func (r *RefundDestinationEnum) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalEnum(data, "refund destination")
+	if err == nil {
+		*r = readRefundDestination(value)
+	}
+	return err
+}
+
+// readRefundDestination keeps a name this version doesn't know
+func readRefundDestination(s string) RefundDestinationEnum {
+	if r, err := ParseRefundDestination(s); err == nil {
+		return r
+	}
+	return RefundDestinationEnum(s)
+}
+
+func normalizeEnum(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+// unmarshalEnum reads the name of an enum, only a value that isn't a string is an error. null is read as unset.
+func unmarshalEnum(data []byte, kind string) (string, error) {
+	if string(data) == "null" {
+		return "", nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", fmt.Errorf("%s must be a string, got %s", kind, data)
+	}
+
+	return value, nil
 }
@@ -0,0 +1,78 @@
+package dana
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnumString(t *testing.T) {
+	assert.Equal(t, "CREDIT_CARD", CreditCard.String())
+	assert.Equal(t, "BACK_OFFICE", BackOffice.String())
+	assert.Equal(t, "TO_SOURCE", ToSource.String())
+	assert.Equal(t, "", PayMethodEnum("").String())
+}
+
+func TestParseEnum(t *testing.T) {
+	method, err := ParsePayMethod("virtual_account")
+	require.NoError(t, err)
+	assert.Equal(t, VirtualAccount, method)
+
+	actor, err := ParseActorType("MERCHANT_OPERATOR")
+	require.NoError(t, err)
+	assert.Equal(t, MerchantOperator, actor)
+
+	_, err = ParseRefundDestination("TO_WALLET")
+	assert.Error(t, err)
+	assert.False(t, RefundDestinationEnum("TO_WALLET").IsValid())
+
+	_, err = ParsePayMethod("")
+	assert.Error(t, err)
+}
+
+func TestEnumJSONInRequests(t *testing.T) {
+	data, err := json.Marshal(RefundRequestData{
+		ActorType:    Merchant,
+		Destination:  ToSource,
+		ActorContext: ActorContext{ActorID: "op-1", ActorType: MerchantOperator},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"actorType":"MERCHANT"`)
+	assert.Contains(t, string(data), `"destination":"TO_SOURCE"`)
+	assert.Contains(t, string(data), `"actorType":"MERCHANT_OPERATOR"`)
+
+	// a refund without actors does not claim any
+	data, err = json.Marshal(RefundRequestData{})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "destination")
+	assert.NotContains(t, string(data), "actorType")
+
+	data, err = json.Marshal(PaymentPreference{DisabledPayMethods: PayMethods{CreditCard, DebitCard}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"disabledPayMethods":"CREDIT_CARD^DEBIT_CARD"}`, string(data))
+}
+
+func TestEnumUnmarshalUnknown(t *testing.T) {
+	var refund RefundRequestData
+	require.NoError(t, json.Unmarshal([]byte(`{"actorType":"system","destination":"TO_WALLET"}`), &refund))
+	assert.Equal(t, System, refund.ActorType)
+	assert.Equal(t, RefundDestinationEnum("TO_WALLET"), refund.Destination)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"actorType":3}`), &refund))
+
+	var preference PaymentPreference
+	require.NoError(t, json.Unmarshal([]byte(`{"disabledPayMethods":"OTC^crypto^COUPON"}`), &preference))
+	assert.Equal(t, PayMethods{Otc, "crypto", Coupon}, preference.DisabledPayMethods)
+
+	// unknown names are written back unchanged
+	data, err := json.Marshal(refund)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"actorType":"SYSTEM"`)
+	assert.Contains(t, string(data), `"destination":"TO_WALLET"`)
+
+	data, err = json.Marshal(preference)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"disabledPayMethods":"OTC^crypto^COUPON"}`, string(data))
+}
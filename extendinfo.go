@@ -0,0 +1,159 @@
+package dana
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+const EXTEND_INFO_MCC = "mcc"
+
+// extendInfoRawKey holds an extendInfo string that is not a JSON object, an empty key DANA doesn't use
+const extendInfoRawKey = ""
+
+// ExtendInfo holds DANA's extendInfo fields. DANA sends and expects them as a JSON object encoded in a
+// string, e.g. "extendInfo":"{\"mcc\":\"5732\"}", ExtendInfo does the encoding so it can be used as a map.
+// An empty ExtendInfo is written as "" and omitted by omitempty fields. Numbers are read as json.Number.
+// A string that is not a JSON object is kept as is, see Raw.
+type ExtendInfo map[string]interface{}
+
+func (e ExtendInfo) MarshalJSON() ([]byte, error) {
+	if len(e) == 0 {
+		return []byte(`""`), nil
+	}
+
+	fields := map[string]interface{}(e)
+	if raw, ok := e[extendInfoRawKey].(string); ok {
+		if len(e) == 1 {
+			return json.Marshal(raw)
+		}
+
+		// fields set after reading a raw string replace it
+		fields = make(map[string]interface{}, len(e)-1)
+		for key, value := range e {
+			if key != extendInfoRawKey {
+				fields[key] = value
+			}
+		}
+	}
+
+	inner, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extend info: %v", err)
+	}
+
+	return json.Marshal(string(inner))
+}
+
+// UnmarshalJSON reads the string form, and also a plain JSON object as some endpoints send. A string that
+// is not a JSON object is kept in Raw instead of failing the whole response.
+func (e *ExtendInfo) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*e = nil
+		return nil
+	}
+
+	var raw *string
+	if len(data) > 0 && data[0] == '"' {
+		var inner string
+		if err := json.Unmarshal(data, &inner); err != nil {
+			return err
+		}
+
+		raw = &inner
+		data = bytes.TrimSpace([]byte(inner))
+		if len(data) == 0 {
+			*e = nil
+			return nil
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	info := map[string]interface{}{}
+	if err := decoder.Decode(&info); err != nil {
+		if raw != nil {
+			*e = ExtendInfo{extendInfoRawKey: *raw}
+			return nil
+		}
+		return fmt.Errorf("extend info is not a JSON object: %s", data)
+	}
+
+	*e = info
+	return nil
+}
+
+// Raw : the extendInfo string DANA sent when it is not a JSON object, it is written back unchanged
+func (e ExtendInfo) Raw() string {
+	raw, _ := e[extendInfoRawKey].(string)
+	return raw
+}
+
+// Set : set key to value, allocating the map when needed
+func (e *ExtendInfo) Set(key string, value interface{}) {
+	if *e == nil {
+		*e = ExtendInfo{}
+	}
+
+	(*e)[key] = value
+}
+
+// GetString : the value of key as a string, numbers and booleans are formatted
+func (e ExtendInfo) GetString(key string) string {
+	switch value := e[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// GetInt64 : the value of key as an integer, which DANA may send as a number or a string
+func (e ExtendInfo) GetInt64(key string) (int64, bool) {
+	switch value := e[key].(type) {
+	case json.Number:
+		i, err := value.Int64()
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(value, 10, 64)
+		return i, err == nil
+	case float64:
+		return int64(value), float64(int64(value)) == value
+	case int:
+		return int64(value), true
+	case int64:
+		return value, true
+	default:
+		return 0, false
+	}
+}
+
+// GetBool : the value of key as a boolean, which DANA may send as a boolean or a string
+func (e ExtendInfo) GetBool(key string) (bool, bool) {
+	switch value := e[key].(type) {
+	case bool:
+		return value, true
+	case string:
+		b, err := strconv.ParseBool(value)
+		return b, err == nil
+	default:
+		return false, false
+	}
+}
+
+// MCC : the merchant category code of an order
+func (e ExtendInfo) MCC() string {
+	return e.GetString(EXTEND_INFO_MCC)
+}
+
+// SetMCC : set the merchant category code of an order
+func (e *ExtendInfo) SetMCC(mcc string) {
+	e.Set(EXTEND_INFO_MCC, mcc)
+}
@@ -0,0 +1,70 @@
+package dana
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendInfoMarshalsAsString(t *testing.T) {
+	req := OrderRequestData{}
+	req.ExtendInfo.SetMCC("5732")
+	req.Order.Goods = []Good{{ExtendInfo: ExtendInfo{"size": 42}}}
+
+	data, err := json.Marshal(req)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"extendInfo":"{\"mcc\":\"5732\"}"`)
+	assert.Contains(t, string(data), `"extendInfo":"{\"size\":42}"`)
+	assert.NotContains(t, string(data), `"extendInfo":""`)
+
+	data, err = json.Marshal(RequestBodyPayFinish{})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"extendInfo":""`)
+}
+
+func TestExtendInfoUnmarshal(t *testing.T) {
+	var view PaymentView
+	err := json.Unmarshal([]byte(`{
+		"extendInfo": "{\"mcc\":\"5732\",\"points\":12345678901,\"topup\":\"true\"}",
+		"payRequestExtendInfo": {"channel": "app"},
+		"payOptionInfos": [{"extendInfo": ""}]
+	}`), &view)
+	require.NoError(t, err)
+
+	assert.Equal(t, "5732", view.ExtendInfo.MCC())
+	points, ok := view.ExtendInfo.GetInt64("points")
+	assert.True(t, ok)
+	assert.Equal(t, int64(12345678901), points)
+	topup, ok := view.ExtendInfo.GetBool("topup")
+	assert.True(t, ok && topup)
+	assert.Equal(t, "app", view.PayRequestExtendInfo.GetString("channel"))
+	require.Len(t, view.PayOptionInfos, 1)
+	assert.Nil(t, view.PayOptionInfos[0].ExtendInfo)
+
+	_, ok = view.ExtendInfo.GetInt64("mcc")
+	assert.True(t, ok)
+	_, ok = view.ExtendInfo.GetBool("missing")
+	assert.False(t, ok)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"extendInfo":42}`), &view))
+}
+
+func TestExtendInfoKeepsInvalidString(t *testing.T) {
+	var view PaymentView
+	require.NoError(t, json.Unmarshal([]byte(`{"extendInfo":"not json","payRequestExtendInfo":"{\"channel\":\"app\"}"}`), &view))
+	assert.Equal(t, "not json", view.ExtendInfo.Raw())
+	assert.Equal(t, "", view.ExtendInfo.MCC())
+	assert.Equal(t, "app", view.PayRequestExtendInfo.GetString("channel"))
+	assert.Equal(t, "", view.PayRequestExtendInfo.Raw())
+
+	data, err := json.Marshal(view)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"extendInfo":"not json"`)
+
+	view.ExtendInfo.SetMCC("5732")
+	data, err = json.Marshal(view.ExtendInfo)
+	require.NoError(t, err)
+	assert.Equal(t, `"{\"mcc\":\"5732\"}"`, string(data))
+}
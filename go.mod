@@ -5,7 +5,6 @@ go 1.13
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/google/uuid v1.1.1
-	github.com/mitchellh/mapstructure v1.1.2
 	github.com/rs/zerolog v1.25.0
 	github.com/stretchr/testify v1.4.0
 	github.com/tidwall/gjson v1.3.2
@@ -0,0 +1,124 @@
+package dana
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// testKeyPair is an RSA key pair in the PEM form accepted by parsePrivateKey and parsePublicKey.
+type testKeyPair struct {
+	private []byte
+	public  []byte
+}
+
+func newTestKeyPair(t *testing.T) testKeyPair {
+	private, public, err := GenerateKeyPair(DEFAULT_KEY_BITS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKeyPair{private: private, public: public}
+}
+
+// fakeDana is a local server answering like DANA: every response is wrapped in the
+// response/signature envelope and signed with the DANA key.
+type fakeDana struct {
+	t        *testing.T
+	server   *httptest.Server
+	merchant testKeyPair
+	dana     testKeyPair
+	handler  func(path string, req Request) interface{}
+	mu       sync.Mutex
+	// header holds the headers of the last request received
+	header http.Header
+}
+
+func newFakeDana(t *testing.T, handler func(path string, req Request) interface{}) *fakeDana {
+	f := &fakeDana{
+		t:        t,
+		merchant: newTestKeyPair(t),
+		dana:     newTestKeyPair(t),
+		handler:  handler,
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+func (f *fakeDana) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.header = r.Header
+	f.mu.Unlock()
+
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		f.t.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var reqBody RequestBody
+	if err := json.Unmarshal(raw, &reqBody); err != nil {
+		f.t.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	response := Response{
+		Head: ResponseHeader{
+			Function:  reqBody.Request.Head.Function,
+			ClientID:  reqBody.Request.Head.ClientID,
+			Version:   reqBody.Request.Head.Version,
+			RespMsgID: reqBody.Request.Head.ReqMsgID,
+		},
+		Body: f.handler(r.URL.Path, reqBody.Request),
+	}
+
+	signature, err := generateSignature(response, f.dana.private)
+	if err != nil {
+		f.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ResponseBody{Response: response, Signature: signature})
+}
+
+func (f *fakeDana) lastHeader() http.Header {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.header
+}
+
+func (f *fakeDana) Close() {
+	f.server.Close()
+}
+
+func (f *fakeDana) gateway() CoreGateway {
+	client := NewClient()
+	client.BaseUrl = f.server.URL
+	client.Version = "2.0"
+	client.ClientId = "test-client"
+	client.ClientSecret = "test-secret"
+	client.PrivateKey = f.merchant.private
+	client.PublicKey = f.dana.public
+
+	return CoreGateway{
+		Client: client,
+	}
+}
+
+// decodeBody re-decodes the generic request body into a typed struct.
+func decodeBody(t *testing.T, body interface{}, v interface{}) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Fatal(err)
+	}
+}
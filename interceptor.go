@@ -0,0 +1,81 @@
+package dana
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// Exchange is a single DANA call as seen by interceptors
+type Exchange struct {
+	// Function is the DANA function called, empty for calls made directly through Client.Call
+	Function string
+	// Request is the request about to be sent. BeforeSend may add headers to it.
+	Request *http.Request
+	// Envelope is the signed request body. It is a copy, changing it does not change what is sent.
+	Envelope []byte
+	// Response and ResponseBody hold DANA's answer. A BeforeSend setting them answers the call in place of DANA.
+	Response     *http.Response
+	ResponseBody []byte
+	// Result is the value the response was decoded into, set before AfterReceive
+	Result interface{}
+}
+
+// Interceptor hooks into every request sent by a Client. Any hook may be nil.
+//
+// BeforeSend hooks run in the order of Client.Interceptors, and an error aborts the call before it is sent.
+// AfterReceive hooks run in reverse order once the response has been decoded and verified, and an error
+// fails the call. OnError hooks run in reverse order with the error of any failed call, including errors
+// returned by other hooks.
+type Interceptor struct {
+	BeforeSend   func(exchange *Exchange) error
+	AfterReceive func(exchange *Exchange) error
+	OnError      func(exchange *Exchange, err error)
+}
+
+func (c *Client) beforeSend(exchange *Exchange) error {
+	for _, interceptor := range c.Interceptors {
+		if interceptor.BeforeSend == nil {
+			continue
+		}
+		if err := interceptor.BeforeSend(exchange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) afterReceive(exchange *Exchange) error {
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		if c.Interceptors[i].AfterReceive == nil {
+			continue
+		}
+		if err := c.Interceptors[i].AfterReceive(exchange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) interceptError(exchange *Exchange, err error) {
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		if c.Interceptors[i].OnError != nil {
+			c.Interceptors[i].OnError(exchange, err)
+		}
+	}
+}
+
+// readBody returns a copy of the request body, leaving the body readable
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
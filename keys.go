@@ -0,0 +1,91 @@
+package dana
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const DEFAULT_KEY_BITS = 2048
+
+// GenerateKeyPair : a new RSA key pair in the PEM forms the client accepts, a PKCS#1 "RSA PRIVATE KEY"
+// for Client.PrivateKey and a PKIX "PUBLIC KEY" to register on DANA's portal
+func GenerateKeyPair(bits int) (privateKey []byte, publicKey []byte, err error) {
+	if bits < DEFAULT_KEY_BITS {
+		return nil, nil, fmt.Errorf("keys must have at least %d bits", DEFAULT_KEY_BITS)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicKey, err = encodePublicKey(&key.PublicKey)
+	return
+}
+
+// PublicKeyFromPrivateKey : the PKIX "PUBLIC KEY" PEM block of a PKCS#1 "RSA PRIVATE KEY" PEM block
+func PublicKeyFromPrivateKey(privateKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != "RSA PRIVATE KEY" {
+		return nil, fmt.Errorf("unsupported key type %q, an RSA PRIVATE KEY is expected", block.Type)
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodePublicKey(&key.PublicKey)
+}
+
+func encodePublicKey(key *rsa.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// SignPayload : the base64 SHA256withRSA signature of data. DANA signs the exact bytes of the "request"
+// or "response" object of an envelope, so data must not be reformatted after it is signed.
+func SignPayload(data []byte, privateKey []byte) (sig string, err error) {
+	signer, err := parsePrivateKey(privateKey)
+	if err != nil {
+		err = fmt.Errorf("signer is damaged: %v", err)
+		return
+	}
+
+	signed, err := signer.Sign(data)
+	if err != nil {
+		err = fmt.Errorf("could not sign request: %v", err)
+		return
+	}
+
+	sig = base64.StdEncoding.EncodeToString(signed)
+	return
+}
+
+// VerifyPayload : verify the base64 SHA256withRSA signature of data
+func VerifyPayload(data []byte, sig string, publicKey []byte) error {
+	parser, err := parsePublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("could not load public key: %v", err)
+	}
+
+	ds, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("signature is not base64: %v", err)
+	}
+
+	return parser.Unsign(data, ds)
+}
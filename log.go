@@ -3,6 +3,7 @@ package dana
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
@@ -21,167 +22,206 @@ const (
 	LOG_LEVEL_INFO   = "info"
 	LOG_LEVEL_WARN   = "warn"
 	LOG_LEVEL_ERROR  = "error"
+
+	DEFAULT_LOG_TIMESTAMP_FORMAT = "2006-01-02T15:04:05-0700"
+
+	LOG_KEY_FUNCTION          = "function"
+	LOG_KEY_PATH              = "path"
+	LOG_KEY_REQ_MSG_ID        = "req_msg_id"
+	LOG_KEY_MERCHANT_TRANS_ID = "merchant_trans_id"
+	LOG_KEY_REQUEST_ID        = "request_id"
+	LOG_KEY_HTTP_STATUS       = "http_status"
+	LOG_KEY_RESULT_STATUS     = "result_status"
+	LOG_KEY_RESULT_CODE_ID    = "result_code_id"
+	LOG_KEY_LATENCY_MS        = "latency_ms"
+	LOG_KEY_RETRIES           = "retries"
+	LOG_KEY_ERROR             = "error"
 )
 
+// LogOption configures a single Logger, it does not touch zerolog's global settings
 type LogOption struct {
 	Format          string
 	Level           string
 	TimestampFormat string
 	CallerToggle    bool
 	Pretty          bool
+	// Output is where lines are written, os.Stderr when nil
+	Output io.Writer
 }
 
+// Logger is safe for concurrent use. Ctx, Method and Str never modify the logger they are called on,
+// they return a derived logger, so a request-scoped logger cannot leak fields into another request's lines.
 type Logger struct {
-	logger    *zerolog.Logger
-	event     *zerolog.Event
-	useCaller bool
-	ctx       context.Context
-	method    string
+	logger     *zerolog.Logger
+	useCaller  bool
+	timeFormat string
+	ctx        context.Context
+	method     string
 }
 
 func NewLogger(option LogOption) *Logger {
-	setLogLevel(option.Level)
-	setTimeFormat(option.TimestampFormat)
-
-	var logger zerolog.Logger
+	out := option.Output
+	if out == nil {
+		out = os.Stderr
+	}
 
 	if option.Pretty {
-		logger = logger.Output(zerolog.ConsoleWriter{Out: os.Stderr, FormatTimestamp: func(i interface{}) string { return time.Now().Format(option.TimestampFormat) }})
-	} else {
-		logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+		// the timestamp is already formatted by the logger, print it as it is
+		out = zerolog.ConsoleWriter{Out: out, FormatTimestamp: func(i interface{}) string { return fmt.Sprint(i) }}
+	}
+
+	logger := zerolog.New(out).Level(parseLogLevel(option.Level))
+
+	timeFormat := option.TimestampFormat
+	if timeFormat == "" {
+		timeFormat = DEFAULT_LOG_TIMESTAMP_FORMAT
 	}
 
 	return &Logger{
-		logger:    &logger,
-		useCaller: option.CallerToggle,
+		logger:     &logger,
+		useCaller:  option.CallerToggle,
+		timeFormat: timeFormat,
 	}
 }
 
+// Ctx returns a logger adding the req_id found in ctx to every line
 func (l *Logger) Ctx(ctx context.Context) *Logger {
-	l.ctx = ctx
-	return l
+	derived := *l
+	derived.ctx = ctx
+	return &derived
 }
 
+// Method returns a logger adding the method name to every line
 func (l *Logger) Method(name string) *Logger {
-	l.method = name
-	return l
+	derived := *l
+	derived.method = name
+	return &derived
 }
 
+// Str returns a logger adding the key/value pair to every line
 func (l *Logger) Str(key string, val string) *Logger {
-	l.event = l.event.Str(key, val)
-	return l
+	logger := l.logger.With().Str(key, val).Logger()
+
+	derived := *l
+	derived.logger = &logger
+	return &derived
+}
+
+// WithFields returns a logger adding every field to every line
+func (l *Logger) WithFields(fields map[string]interface{}) LogInterface {
+	logger := l.logger.With().Fields(fields).Logger()
+
+	derived := *l
+	derived.logger = &logger
+	return &derived
 }
 
 func (l *Logger) Trace(format string, v ...interface{}) {
-	l.logEvent(LOG_LEVEL_TRACE).withCtx().withCaller().withMethod().msgf(format, v...)
+	l.log(LOG_LEVEL_TRACE, l.useCaller, format, v...)
 }
 
 func (l *Logger) Debug(format string, v ...interface{}) {
-	l.logEvent(LOG_LEVEL_DEBUG).withCtx().withCaller().withMethod().msgf(format, v...)
+	l.log(LOG_LEVEL_DEBUG, l.useCaller, format, v...)
 }
 
 func (l *Logger) Info(format string, v ...interface{}) {
-	l.logEvent(LOG_LEVEL_INFO).withCtx().withMethod().msgf(format, v...)
+	l.log(LOG_LEVEL_INFO, false, format, v...)
 }
 
 func (l *Logger) Warn(format string, v ...interface{}) {
-	l.logEvent(LOG_LEVEL_WARN).withCtx().withCaller().withMethod().msgf(format, v...)
+	l.log(LOG_LEVEL_WARN, l.useCaller, format, v...)
 }
 
 func (l *Logger) Error(format string, v ...interface{}) {
-	l.logEvent(LOG_LEVEL_ERROR).withCtx().withCaller().withMethod().msgf(format, v...)
+	l.log(LOG_LEVEL_ERROR, l.useCaller, format, v...)
 }
 
-func (l *Logger) msgf(format string, v ...interface{}) {
-	l.event.Msgf(format, v...)
+// log builds and sends a single event. It must be called directly from the exported level methods,
+// the caller lookup relies on that stack depth.
+func (l *Logger) log(level string, withCaller bool, format string, v ...interface{}) {
+	event := l.logEvent(level)
+	if event == nil {
+		return
+	}
+
+	if withCaller {
+		event = withCallerField(event)
+	}
+
+	// zerolog's own timestamp uses the global zerolog.TimeFieldFormat, so it is formatted here instead
+	if l.timeFormat != "" {
+		event = event.Str(zerolog.TimestampFieldName, time.Now().Format(l.timeFormat))
+	}
+
+	event = l.withCtx(event)
+	event = l.withMethod(event)
+	event.Msgf(format, v...)
 }
 
-func (l *Logger) withCtx() *Logger {
+func (l *Logger) withCtx(event *zerolog.Event) *zerolog.Event {
 	if l.ctx == nil {
-		return l
+		return event
 	}
 
 	reqID, ok := l.ctx.Value(LOG_KEY_REQ_ID).(string)
 	if !ok {
-		return l
+		return event
 	}
 
-	l.event = l.event.Str(LOG_KEY_REQ_ID, reqID)
-	return l
+	return event.Str(LOG_KEY_REQ_ID, reqID)
 }
 
-func (l *Logger) withMethod() *Logger {
+func (l *Logger) withMethod(event *zerolog.Event) *zerolog.Event {
 	if l.method == "" {
-		return l
+		return event
 	}
 
-	l.event = l.event.Str(LOG_KEY_METHOD, l.method)
-	return l
+	return event.Str(LOG_KEY_METHOD, l.method)
 }
 
-func (l *Logger) withCaller() *Logger {
-	if !l.useCaller {
-		return l
-	}
-
-	skip := 2 // go back up to 2 stack before printing log to know the true caller
+func withCallerField(event *zerolog.Event) *zerolog.Event {
+	skip := 3 // withCallerField, log and the level method come before the true caller
 	_, file, line, ok := runtime.Caller(skip)
 	if !ok {
-		return l
+		return event
 	}
 
 	fileparts := strings.Split(file, APPLICATION_NAME)
 	shortname := fmt.Sprintf("%s%s", APPLICATION_NAME, fileparts[len(fileparts)-1])
 
-	l.event = l.event.Str(LOG_KEY_CALLER, fmt.Sprintf("%s:%d", shortname, line))
-	return l
+	return event.Str(LOG_KEY_CALLER, fmt.Sprintf("%s:%d", shortname, line))
 }
 
-func (l *Logger) logEvent(level string) *Logger {
+func (l *Logger) logEvent(level string) *zerolog.Event {
 	switch level {
 	case LOG_LEVEL_TRACE:
-		l.event = l.logger.Trace()
-		return l
+		return l.logger.Trace()
 	case LOG_LEVEL_DEBUG:
-		l.event = l.logger.Debug()
-		return l
+		return l.logger.Debug()
 	case LOG_LEVEL_INFO:
-		l.event = l.logger.Info()
-		return l
+		return l.logger.Info()
 	case LOG_LEVEL_WARN:
-		l.event = l.logger.Warn()
-		return l
+		return l.logger.Warn()
 	case LOG_LEVEL_ERROR:
-		l.event = l.logger.Error()
-		return l
+		return l.logger.Error()
 	default:
-		l.event = l.logger.Trace()
-		return l
+		return l.logger.Trace()
 	}
 }
 
-func setLogLevel(level string) {
+func parseLogLevel(level string) zerolog.Level {
 	switch level {
 	case LOG_LEVEL_TRACE:
-		zerolog.SetGlobalLevel(zerolog.TraceLevel)
+		return zerolog.TraceLevel
 	case LOG_LEVEL_DEBUG:
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		return zerolog.DebugLevel
 	case LOG_LEVEL_INFO:
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+		return zerolog.InfoLevel
 	case LOG_LEVEL_WARN:
-		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+		return zerolog.WarnLevel
 	case LOG_LEVEL_ERROR:
-		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+		return zerolog.ErrorLevel
 	default:
-		zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	}
-}
-
-func setTimeFormat(format string) {
-	if format != "" {
-		zerolog.TimeFieldFormat = format
-		return
+		return zerolog.WarnLevel
 	}
-
-	zerolog.TimeFieldFormat = "2006-01-02T15:04:05-0700"
 }
@@ -0,0 +1,143 @@
+package dana
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func newBufferLogger(out *syncBuffer) *Logger {
+	return NewLogger(LogOption{Level: LOG_LEVEL_DEBUG, Output: out})
+}
+
+func TestLoggerDerivedLoggersDoNotShareFields(t *testing.T) {
+	out := &syncBuffer{}
+	base := newBufferLogger(out)
+
+	derived := base.Method("Order").Str("merchantTransId", "ORDER-1")
+	base.Info("base")
+	derived.Info("derived")
+
+	lines := bytes.Split(bytes.TrimSpace(out.buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.NotContains(t, string(lines[0]), "ORDER-1")
+	assert.NotContains(t, string(lines[0]), LOG_KEY_METHOD)
+	assert.Contains(t, string(lines[1]), `"merchantTransId":"ORDER-1"`)
+	assert.Contains(t, string(lines[1]), `"method":"Order"`)
+}
+
+// Run with -race: concurrent gateway calls share one client logger, and every line the gateway writes
+// carries the req_id of the call it belongs to. Odd calls log through their own logger set with WithLogger.
+func TestLoggerConcurrentGatewayCalls(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		return OrderDetailData{ResultInfo: ResultInfo{ResultStatus: "S"}}
+	})
+	defer fake.Close()
+
+	out := &syncBuffer{}
+	base := newBufferLogger(out)
+
+	gateway := fake.gateway()
+	gateway.Client.Logger = base
+	gateway.Client.LogLevel = 3
+
+	const calls = 50
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			reqID := fmt.Sprintf("req-%d", i)
+			ctx := context.WithValue(context.Background(), LOG_KEY_REQ_ID, reqID)
+			if i%2 == 1 {
+				ctx = WithLogger(ctx, base.Ctx(ctx).Method("OrderDetail"))
+			}
+
+			_, err := gateway.WithContext(ctx).OrderDetail(&OrderDetailRequestData{MerchantID: "m", MerchantTransID: reqID}, "")
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	summaries := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(out.buf.Bytes()))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+
+		reqID, ok := line[LOG_KEY_REQ_ID].(string)
+		require.True(t, ok, "line without req_id: %s", scanner.Text())
+
+		var i int
+		_, err := fmt.Sscanf(reqID, "req-%d", &i)
+		require.NoError(t, err)
+		if i%2 == 1 {
+			assert.Equal(t, "OrderDetail", line[LOG_KEY_METHOD], scanner.Text())
+		} else {
+			assert.NotContains(t, line, LOG_KEY_METHOD, scanner.Text())
+		}
+
+		message := line["message"].(string)
+		if message == LOG_MESSAGE_CALL_SUMMARY {
+			summaries[reqID]++
+			assert.Equal(t, reqID, line[LOG_KEY_MERCHANT_TRANS_ID])
+		}
+		if strings.Contains(message, `"merchantTransId":"req-`) {
+			assert.Contains(t, message, `"merchantTransId":"`+reqID+`"`)
+		}
+	}
+
+	assert.Len(t, summaries, calls)
+	for reqID, count := range summaries {
+		assert.Equal(t, 1, count, reqID)
+	}
+}
+
+func TestNewLoggerLeavesGlobalStateAlone(t *testing.T) {
+	globalLevel, timeFieldFormat := zerolog.GlobalLevel(), zerolog.TimeFieldFormat
+
+	out := &syncBuffer{}
+	logger := NewLogger(LogOption{Level: LOG_LEVEL_ERROR, TimestampFormat: "2006-01-02", Output: out})
+
+	assert.Equal(t, globalLevel, zerolog.GlobalLevel())
+	assert.Equal(t, timeFieldFormat, zerolog.TimeFieldFormat)
+
+	logger.Info("filtered")
+	logger.Error("kept")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.buf.Bytes(), &line))
+	assert.Equal(t, "kept", line["message"])
+	assert.Regexp(t, `^\d{4}-\d{2}-\d{2}$`, line[zerolog.TimestampFieldName])
+}
+
+func TestNewLoggerPretty(t *testing.T) {
+	out := &syncBuffer{}
+	logger := NewLogger(LogOption{Level: LOG_LEVEL_INFO, TimestampFormat: "2006", Pretty: true, Output: out})
+
+	logger.Info("hello")
+
+	assert.Regexp(t, `^\d{4} .*hello`, out.buf.String())
+}
@@ -0,0 +1,71 @@
+package dana
+
+import "time"
+
+const (
+	WEBHOOK_OUTCOME_VERIFIED          = "verified"
+	WEBHOOK_OUTCOME_INVALID_SIGNATURE = "invalid_signature"
+
+	WEBHOOK_KIND_SNAP = "SNAP"
+)
+
+// CallMetric describes the outcome of a single DANA call. Function is the DANA function of legacy calls
+// and the path of SNAP calls. HTTPStatus is 0 when no response was received.
+type CallMetric struct {
+	Function     string
+	HTTPStatus   int
+	ResultStatus string
+	ResultCodeID string
+	Latency      time.Duration
+	Err          error
+}
+
+// Metrics receives measurements of the library's activity. Client.Metrics is nil by default, which
+// disables them. Package danaprom provides a Prometheus implementation.
+type Metrics interface {
+	// ObserveCall is called once for every DANA call
+	ObserveCall(call CallMetric)
+	// SignatureVerificationFailed is called when a DANA response signature does not verify
+	SignatureVerificationFailed(function string)
+	// WebhookReceived is called for every notification verified through the gateways, kind is the DANA
+	// function of the notification or WEBHOOK_KIND_SNAP
+	WebhookReceived(kind string, outcome string)
+}
+
+func (c *Client) observeCall(summary *callSummary) {
+	if c.Metrics == nil {
+		return
+	}
+
+	function := summary.Function
+	if function == "" {
+		function = summary.Path
+	}
+
+	c.Metrics.ObserveCall(CallMetric{
+		Function:     function,
+		HTTPStatus:   summary.HTTPStatus,
+		ResultStatus: summary.ResultStatus,
+		ResultCodeID: summary.ResultCodeID,
+		Latency:      summary.Latency,
+		Err:          summary.Err,
+	})
+}
+
+func (c *Client) observeSignatureFailure(function string) {
+	if c.Metrics != nil {
+		c.Metrics.SignatureVerificationFailed(function)
+	}
+}
+
+func (c *Client) observeWebhook(kind string, err error) {
+	if c.Metrics == nil {
+		return
+	}
+
+	outcome := WEBHOOK_OUTCOME_VERIFIED
+	if err != nil {
+		outcome = WEBHOOK_OUTCOME_INVALID_SIGNATURE
+	}
+	c.Metrics.WebhookReceived(kind, outcome)
+}
@@ -0,0 +1,354 @@
+package dana
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TERMINAL_TYPE_APP    = "APP"
+	TERMINAL_TYPE_WEB    = "WEB"
+	TERMINAL_TYPE_WAP    = "WAP"
+	TERMINAL_TYPE_SYSTEM = "SYSTEM"
+
+	SOURCE_PLATFORM_IPG = "IPG"
+
+	NOTIFICATION_URL_TYPE_PAY_RETURN   = "PAY_RETURN"
+	NOTIFICATION_URL_TYPE_NOTIFICATION = "NOTIFICATION"
+
+	DEFAULT_ORDER_EXPIRY = 30 * time.Minute
+)
+
+// OrderBuilder builds an OrderRequestData for CoreGateway.Order, computing the order amount from the goods
+// and shipping charges so the two always agree. Amounts are given in rupiah, e.g. 15000 for Rp 15.000. The
+// request holds the order amount in rupiah, as CoreGateway.Order expects it, and goods prices and shipping
+// charges in DANA's minor units, e.g. "1500000".
+//
+//	req, err := dana.NewOrderBuilder("MERCHANT_ID", "ORDER-1").
+//		Title("Donation").
+//		ProductCode("PRODUCT_CODE").
+//		AddGood(dana.Good{Description: "Donation"}, 15000, 1).
+//		TerminalType(dana.TERMINAL_TYPE_WEB).
+//		PayReturnURL("https://example.com/return").
+//		NotificationURL("https://example.com/notify").
+//		Build()
+type OrderBuilder struct {
+	req         OrderRequestData
+	amount      int64
+	goodsTotal  int64
+	hasAmount   bool
+	createdTime time.Time
+	expiry      time.Duration
+	expiryTime  time.Time
+	problems    []string
+}
+
+// NewOrderBuilder : start an order for merchantID, identified on the merchant side by merchantTransID
+func NewOrderBuilder(merchantID, merchantTransID string) *OrderBuilder {
+	b := &OrderBuilder{expiry: DEFAULT_ORDER_EXPIRY}
+	b.req.MerchantID = merchantID
+	b.req.Order.MerchantTransID = merchantTransID
+	b.req.EnvInfo = DefaultEnvInfo(TERMINAL_TYPE_SYSTEM)
+
+	return b
+}
+
+// DefaultEnvInfo : the EnvInfo of an order placed from terminalType
+func DefaultEnvInfo(terminalType string) EnvInfo {
+	env := EnvInfo{
+		SourcePlatform:    SOURCE_PLATFORM_IPG,
+		TerminalType:      terminalType,
+		OrderTerminalType: terminalType,
+	}
+
+	if terminalType == TERMINAL_TYPE_WEB || terminalType == TERMINAL_TYPE_WAP {
+		env.WebsiteLanguage = "id"
+	}
+
+	return env
+}
+
+func (b *OrderBuilder) Title(title string) *OrderBuilder {
+	b.req.Order.OrderTitle = title
+	return b
+}
+
+func (b *OrderBuilder) Memo(memo string) *OrderBuilder {
+	b.req.Order.OrderMemo = memo
+	return b
+}
+
+func (b *OrderBuilder) ProductCode(productCode string) *OrderBuilder {
+	b.req.ProductCode = productCode
+	return b
+}
+
+func (b *OrderBuilder) MCC(mcc string) *OrderBuilder {
+	b.req.Mcc = mcc
+	return b
+}
+
+func (b *OrderBuilder) ExtendInfo(key string, value interface{}) *OrderBuilder {
+	b.req.ExtendInfo.Set(key, value)
+	return b
+}
+
+// AddGood : add quantity of good at price (in rupiah) each. Price and Quantity of good are set by the builder.
+func (b *OrderBuilder) AddGood(good Good, price int64, quantity int64) *OrderBuilder {
+	if price < 0 || quantity <= 0 {
+		b.problems = append(b.problems, fmt.Sprintf("good %q must have a positive quantity and price", good.Description))
+		return b
+	}
+
+	good.Price = toDanaAmount(Amount{Value: strconv.FormatInt(price, 10)})
+	good.Quantity = strconv.FormatInt(quantity, 10)
+	b.req.Order.Goods = append(b.req.Order.Goods, good)
+	b.goodsTotal += price * quantity
+
+	return b
+}
+
+// AddShipping : add a shipping destination, charge (in rupiah) is added to the order amount
+func (b *OrderBuilder) AddShipping(info ShippingInfo, charge int64) *OrderBuilder {
+	if charge < 0 {
+		b.problems = append(b.problems, fmt.Sprintf("shipping %q must have a positive charge", info.MerchantShippingID))
+		return b
+	}
+
+	if charge > 0 {
+		info.ChargeAmount = toDanaAmount(Amount{Value: strconv.FormatInt(charge, 10)})
+	}
+
+	b.req.Order.ShippingInfo = append(b.req.Order.ShippingInfo, info)
+	b.goodsTotal += charge
+
+	return b
+}
+
+// Amount : set the order amount (in rupiah) of an order without goods. With goods, Build checks that it matches them.
+func (b *OrderBuilder) Amount(amount int64) *OrderBuilder {
+	b.amount = amount
+	b.hasAmount = true
+	return b
+}
+
+// CreatedAt : the time the order was created, the time of Build by default
+func (b *OrderBuilder) CreatedAt(t time.Time) *OrderBuilder {
+	b.createdTime = t
+	return b
+}
+
+// ExpiresIn : expire the order d after it was created, DEFAULT_ORDER_EXPIRY by default
+func (b *OrderBuilder) ExpiresIn(d time.Duration) *OrderBuilder {
+	b.expiry = d
+	b.expiryTime = time.Time{}
+	return b
+}
+
+// ExpiresAt : expire the order at t
+func (b *OrderBuilder) ExpiresAt(t time.Time) *OrderBuilder {
+	b.expiryTime = t
+	return b
+}
+
+// TerminalType : set the EnvInfo defaults of terminalType, one of the TERMINAL_TYPE constants
+func (b *OrderBuilder) TerminalType(terminalType string) *OrderBuilder {
+	env := DefaultEnvInfo(terminalType)
+	env.ClientIP = b.req.EnvInfo.ClientIP
+	env.ExtendInfo = b.req.EnvInfo.ExtendInfo
+	b.req.EnvInfo = env
+	return b
+}
+
+// EnvInfo : replace the EnvInfo defaults
+func (b *OrderBuilder) EnvInfo(env EnvInfo) *OrderBuilder {
+	b.req.EnvInfo = env
+	return b
+}
+
+func (b *OrderBuilder) ClientIP(ip string) *OrderBuilder {
+	b.req.EnvInfo.ClientIP = ip
+	return b
+}
+
+// PayReturnURL : where DANA sends the user after the payment
+func (b *OrderBuilder) PayReturnURL(u string) *OrderBuilder {
+	return b.notificationURL(NOTIFICATION_URL_TYPE_PAY_RETURN, u)
+}
+
+// NotificationURL : where DANA sends the finish payment notification
+func (b *OrderBuilder) NotificationURL(u string) *OrderBuilder {
+	return b.notificationURL(NOTIFICATION_URL_TYPE_NOTIFICATION, u)
+}
+
+func (b *OrderBuilder) notificationURL(urlType, u string) *OrderBuilder {
+	if b.req.NotificationUrls == nil {
+		b.req.NotificationUrls = &[]NotificationUrl{}
+	}
+
+	urls := *b.req.NotificationUrls
+	for i := range urls {
+		if urls[i].Type == urlType {
+			urls[i].URL = u
+			return b
+		}
+	}
+
+	*b.req.NotificationUrls = append(urls, NotificationUrl{URL: u, Type: urlType})
+	return b
+}
+
+// DisablePayMethods : prevent the user from paying with methods
+func (b *OrderBuilder) DisablePayMethods(methods ...PayMethodEnum) *OrderBuilder {
+	if b.req.PaymentPreference == nil {
+		b.req.PaymentPreference = &PaymentPreference{}
+	}
+
+	b.req.PaymentPreference.DisabledPayMethods = append(b.req.PaymentPreference.DisabledPayMethods, methods...)
+	return b
+}
+
+// Build : the order request, or an error listing everything DANA would reject
+func (b *OrderBuilder) Build() (*OrderRequestData, error) {
+	req := b.req
+	req.Order.Goods = append([]Good(nil), b.req.Order.Goods...)
+	req.Order.ShippingInfo = append([]ShippingInfo(nil), b.req.Order.ShippingInfo...)
+	if b.req.NotificationUrls != nil {
+		urls := append([]NotificationUrl(nil), (*b.req.NotificationUrls)...)
+		req.NotificationUrls = &urls
+	}
+
+	amount := b.amount
+	if !b.hasAmount {
+		amount = b.goodsTotal
+	}
+	req.Order.OrderAmount = Amount{Currency: CURRENCY_IDR, Value: strconv.FormatInt(amount, 10)}
+
+	created := b.createdTime
+	if created.IsZero() {
+		created = time.Now()
+	}
+	expiry := b.expiryTime
+	if expiry.IsZero() {
+		expiry = created.Add(b.expiry)
+	}
+	req.Order.CreatedTime = NewDanaTime(created.Truncate(time.Second))
+	req.Order.ExpiryTime = NewDanaTime(expiry.Truncate(time.Second))
+
+	problems := append([]string(nil), b.problems...)
+	if err := req.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid order: %s", strings.Join(problems, "; "))
+	}
+
+	return &req, nil
+}
+
+// Validate : check the order request before it is sent with CoreGateway.Order. The order amount is in rupiah,
+// as CoreGateway.Order expects it, while goods prices and shipping charges are in DANA's minor units.
+func (req *OrderRequestData) Validate() error {
+	var problems []string
+
+	if req.MerchantID == "" {
+		problems = append(problems, "merchantId is required")
+	}
+	if req.ProductCode == "" {
+		problems = append(problems, "productCode is required")
+	}
+	if req.Order.MerchantTransID == "" {
+		problems = append(problems, "merchantTransId is required")
+	}
+	if req.Order.OrderTitle == "" {
+		problems = append(problems, "orderTitle is required")
+	}
+
+	amount, err := strconv.ParseInt(req.Order.OrderAmount.Value, 10, 64)
+	if err != nil || amount <= 0 {
+		problems = append(problems, fmt.Sprintf("order amount %q must be a positive number of rupiah", req.Order.OrderAmount.Value))
+	}
+
+	if len(req.Order.Goods) > 0 && err == nil {
+		total, ok := goodsTotal(req.Order)
+		if !ok {
+			problems = append(problems, "goods prices and quantities must be numbers")
+		} else if total != amount*100 {
+			problems = append(problems, fmt.Sprintf("goods and shipping add up to %d, not the order amount %d00", total, amount))
+		}
+	}
+
+	created, expiry := req.Order.CreatedTime, req.Order.ExpiryTime
+	if created != nil && expiry != nil && !expiry.After(created.Time) {
+		problems = append(problems, "expiryTime must be after createdTime")
+	}
+
+	switch req.EnvInfo.TerminalType {
+	case TERMINAL_TYPE_APP, TERMINAL_TYPE_WEB, TERMINAL_TYPE_WAP, TERMINAL_TYPE_SYSTEM:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown terminal type %q", req.EnvInfo.TerminalType))
+	}
+	if req.EnvInfo.SourcePlatform == "" {
+		problems = append(problems, "envInfo sourcePlatform is required")
+	}
+
+	if req.NotificationUrls != nil {
+		for _, n := range *req.NotificationUrls {
+			if u, err := url.Parse(n.URL); err != nil || !u.IsAbs() {
+				problems = append(problems, fmt.Sprintf("%s url %q must be absolute", n.Type, n.URL))
+			}
+		}
+	}
+
+	if req.PaymentPreference != nil {
+		for _, method := range req.PaymentPreference.DisabledPayMethods {
+			if !method.IsValid() {
+				problems = append(problems, fmt.Sprintf("unknown pay method %q", method))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// goodsTotal adds up the goods and shipping charges of order in minor units
+func goodsTotal(order Order) (total int64, ok bool) {
+	for _, good := range order.Goods {
+		price, err := strconv.ParseInt(good.Price.Value, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		quantity := int64(1)
+		if good.Quantity != "" {
+			if quantity, err = strconv.ParseInt(good.Quantity, 10, 64); err != nil {
+				return 0, false
+			}
+		}
+
+		total += price * quantity
+	}
+
+	for _, shipping := range order.ShippingInfo {
+		if shipping.ChargeAmount.Value == "" {
+			continue
+		}
+
+		charge, err := strconv.ParseInt(shipping.ChargeAmount.Value, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		total += charge
+	}
+
+	return total, true
+}
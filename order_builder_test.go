@@ -0,0 +1,108 @@
+package dana
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderBuilder(t *testing.T) {
+	created := time.Date(2020, 10, 1, 4, 0, 0, 0, time.UTC)
+
+	req, err := NewOrderBuilder("216620000000000000000", "ORDER-1").
+		Title("Donation").
+		ProductCode("51051000100000000001").
+		AddGood(Good{MerchantGoodsID: "g-1", Description: "Book", Category: "books"}, 15000, 2).
+		AddGood(Good{MerchantGoodsID: "g-2", Description: "Pen"}, 2500, 1).
+		AddShipping(ShippingInfo{MerchantShippingID: "s-1"}, 10000).
+		CreatedAt(created).
+		ExpiresIn(time.Hour).
+		TerminalType(TERMINAL_TYPE_WEB).
+		ClientIP("10.0.0.1").
+		PayReturnURL("https://example.com/return").
+		NotificationURL("https://example.com/notify").
+		NotificationURL("https://example.com/notify/v2").
+		DisablePayMethods(CreditCard, Otc).
+		Build()
+	require.NoError(t, err)
+
+	assert.Equal(t, Amount{Currency: CURRENCY_IDR, Value: "42500"}, req.Order.OrderAmount)
+	assert.Equal(t, Amount{Currency: CURRENCY_IDR, Value: "1500000"}, req.Order.Goods[0].Price)
+	assert.Equal(t, "2", req.Order.Goods[0].Quantity)
+	assert.Equal(t, "1000000", req.Order.ShippingInfo[0].ChargeAmount.Value)
+	assert.Equal(t, "2020-10-01T11:00:00+07:00", req.Order.CreatedTime.String())
+	assert.Equal(t, "2020-10-01T12:00:00+07:00", req.Order.ExpiryTime.String())
+	assert.Equal(t, EnvInfo{
+		SourcePlatform:    SOURCE_PLATFORM_IPG,
+		TerminalType:      TERMINAL_TYPE_WEB,
+		OrderTerminalType: TERMINAL_TYPE_WEB,
+		WebsiteLanguage:   "id",
+		ClientIP:          "10.0.0.1",
+	}, req.EnvInfo)
+	assert.Equal(t, []NotificationUrl{
+		{URL: "https://example.com/return", Type: NOTIFICATION_URL_TYPE_PAY_RETURN},
+		{URL: "https://example.com/notify/v2", Type: NOTIFICATION_URL_TYPE_NOTIFICATION},
+	}, *req.NotificationUrls)
+	assert.Equal(t, "CREDIT_CARD^OTC", req.PaymentPreference.DisabledPayMethods.String())
+}
+
+func TestOrderBuilderValidation(t *testing.T) {
+	_, err := NewOrderBuilder("", "ORDER-1").
+		Title("Donation").
+		AddGood(Good{Description: "Book"}, 15000, 0).
+		AddGood(Good{Description: "Pen"}, 2500, 1).
+		Amount(20000).
+		ExpiresIn(-time.Minute).
+		TerminalType("KIOSK").
+		NotificationURL("/notify").
+		DisablePayMethods(Otc, "CRYPTO").
+		Build()
+	require.Error(t, err)
+
+	for _, problem := range []string{
+		`good "Book" must have a positive quantity and price`,
+		"merchantId is required",
+		"productCode is required",
+		"goods and shipping add up to 250000, not the order amount 2000000",
+		"expiryTime must be after createdTime",
+		`unknown terminal type "KIOSK"`,
+		`NOTIFICATION url "/notify" must be absolute`,
+		`unknown pay method "CRYPTO"`,
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+
+	_, err = NewOrderBuilder("m", "ORDER-2").Title("Top up").ProductCode("p").Build()
+	assert.Contains(t, err.Error(), `order amount "0" must be a positive number of rupiah`)
+
+	req, err := NewOrderBuilder("m", "ORDER-3").Title("Top up").ProductCode("p").Amount(50000).Build()
+	require.NoError(t, err)
+	assert.Equal(t, TERMINAL_TYPE_SYSTEM, req.EnvInfo.TerminalType)
+	assert.True(t, req.Order.ExpiryTime.Sub(req.Order.CreatedTime.Time) == DEFAULT_ORDER_EXPIRY)
+}
+
+func TestOrderBuilderSendsMatchingAmounts(t *testing.T) {
+	var received OrderRequestData
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		decodeBody(t, req.Body, &received)
+		return OrderResponseData{ResultInfo: ResultInfo{ResultStatus: "S"}, CheckoutURL: "https://m.dana.id/checkout"}
+	})
+	defer fake.Close()
+	gateway := fake.gateway()
+
+	req, err := NewOrderBuilder("m", "ORDER-1").
+		Title("Donation").
+		ProductCode("p").
+		AddGood(Good{Description: "Book"}, 15000, 2).
+		Build()
+	require.NoError(t, err)
+
+	_, err = gateway.Order(req, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "3000000", received.Order.OrderAmount.Value)
+	assert.Equal(t, "1500000", received.Order.Goods[0].Price.Value)
+	assert.NotNil(t, received.Order.ExpiryTime)
+}
@@ -0,0 +1,227 @@
+// Package reconciliation compares the orders a merchant keeps locally against what DANA reports
+// for them through CoreGateway.OrderDetail, and lists every order the two disagree on.
+package reconciliation
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	dana "github.com/kitabisa/sangu-dana"
+)
+
+const (
+	DISCREPANCY_MISSING         = "MISSING"
+	DISCREPANCY_STATUS_MISMATCH = "STATUS_MISMATCH"
+	DISCREPANCY_AMOUNT_MISMATCH = "AMOUNT_MISMATCH"
+	DISCREPANCY_REFUND_MISMATCH = "REFUND_MISMATCH"
+	DISCREPANCY_QUERY_FAILED    = "QUERY_FAILED"
+
+	RESULT_STATUS_SUCCESS       = "S"
+	RESULT_CODE_ORDER_NOT_EXIST = "ORDER_NOT_EXIST"
+
+	DEFAULT_CONCURRENCY = 4
+)
+
+// LocalOrder is the merchant's own view of an order. Amounts are in minor units, the same unit DANA
+// returns in AmountDetail (Rp 15.000 is 1500000).
+type LocalOrder struct {
+	MerchantTransID string
+	AcquirementID   string
+	Status          dana.AcquirementStatus
+	Amount          int64
+	RefundedAmount  int64
+}
+
+// OrderSource provides the local orders to reconcile.
+type OrderSource interface {
+	Orders(ctx context.Context) ([]LocalOrder, error)
+}
+
+// OrderQuerier fetches an order from DANA, abandoning the query when ctx is done. Wrap a *dana.CoreGateway
+// with GatewayQuerier to use it.
+type OrderQuerier interface {
+	OrderDetail(ctx context.Context, reqBody *dana.OrderDetailRequestData, accessToken string) (dana.OrderDetailResponse, error)
+}
+
+// GatewayQuerier queries orders with a CoreGateway, sending each query with the context it is given.
+type GatewayQuerier struct {
+	Gateway *dana.CoreGateway
+}
+
+// OrderDetail queries the order with a copy of the gateway bound to ctx.
+func (q GatewayQuerier) OrderDetail(ctx context.Context, reqBody *dana.OrderDetailRequestData, accessToken string) (dana.OrderDetailResponse, error) {
+	return q.Gateway.WithContext(ctx).OrderDetail(reqBody, accessToken)
+}
+
+// Discrepancy describes one way a local order disagrees with DANA. Local and Remote hold the
+// compared values, formatted for the report.
+type Discrepancy struct {
+	Type            string
+	MerchantTransID string
+	AcquirementID   string
+	Local           string
+	Remote          string
+	Err             error
+}
+
+// Report is the outcome of a reconciliation run. Discrepancies are listed in the order of the local orders.
+type Report struct {
+	Checked       int
+	Matched       int
+	Discrepancies []Discrepancy
+}
+
+// WriteCSV writes the discrepancies as CSV, one line per discrepancy, preceded by a header line.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"type", "merchantTransId", "acquirementId", "local", "dana", "error"}); err != nil {
+		return err
+	}
+
+	for _, d := range r.Discrepancies {
+		errMsg := ""
+		if d.Err != nil {
+			errMsg = d.Err.Error()
+		}
+		if err := cw.Write([]string{d.Type, d.MerchantTransID, d.AcquirementID, d.Local, d.Remote, errMsg}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Reconciler queries DANA for every local order, at most Concurrency orders at a time.
+type Reconciler struct {
+	Gateway     OrderQuerier
+	MerchantID  string
+	Concurrency int
+}
+
+// Run reconciles every order of source. It only returns an error when the local orders cannot be
+// loaded or ctx is done; failures to query a single order are reported as DISCREPANCY_QUERY_FAILED.
+// Queries in flight when ctx is done are abandoned and reported as failed.
+func (r *Reconciler) Run(ctx context.Context, source OrderSource) (report Report, err error) {
+	orders, err := source.Orders(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to load local orders: %v", err)
+	}
+
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = DEFAULT_CONCURRENCY
+	}
+
+	results := make([][]Discrepancy, len(orders))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i := range orders {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return report, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = r.reconcile(ctx, orders[i])
+		}(i)
+	}
+	wg.Wait()
+
+	report.Checked = len(orders)
+	for _, discrepancies := range results {
+		if len(discrepancies) == 0 {
+			report.Matched++
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancies...)
+	}
+
+	return report, nil
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, order LocalOrder) []Discrepancy {
+	base := Discrepancy{
+		MerchantTransID: order.MerchantTransID,
+		AcquirementID:   order.AcquirementID,
+	}
+
+	res, err := r.Gateway.OrderDetail(ctx, &dana.OrderDetailRequestData{
+		MerchantID:      r.MerchantID,
+		AcquirementID:   order.AcquirementID,
+		MerchantTransID: order.MerchantTransID,
+	}, "")
+	if err != nil {
+		base.Type = DISCREPANCY_QUERY_FAILED
+		base.Err = err
+		return []Discrepancy{base}
+	}
+
+	detail := res.Response.Body
+	if detail.ResultInfo.ResultStatus != RESULT_STATUS_SUCCESS {
+		if detail.ResultInfo.ResultCode == RESULT_CODE_ORDER_NOT_EXIST {
+			base.Type = DISCREPANCY_MISSING
+			base.Local = string(order.Status)
+			return []Discrepancy{base}
+		}
+
+		base.Type = DISCREPANCY_QUERY_FAILED
+		base.Err = fmt.Errorf("dana returned %s %s: %s", detail.ResultInfo.ResultStatus, detail.ResultInfo.ResultCode, detail.ResultInfo.ResultMsg)
+		return []Discrepancy{base}
+	}
+
+	if base.AcquirementID == "" {
+		base.AcquirementID = detail.AcquirementID
+	}
+
+	var discrepancies []Discrepancy
+
+	if !strings.EqualFold(string(order.Status), string(detail.StatusDetail.AcquirementStatus)) {
+		d := base
+		d.Type = DISCREPANCY_STATUS_MISMATCH
+		d.Local = string(order.Status)
+		d.Remote = string(detail.StatusDetail.AcquirementStatus)
+		discrepancies = append(discrepancies, d)
+	}
+
+	if d, ok := compareAmount(base, DISCREPANCY_AMOUNT_MISMATCH, order.Amount, detail.AmountDetail.OrderAmount); !ok {
+		discrepancies = append(discrepancies, d)
+	}
+
+	if d, ok := compareAmount(base, DISCREPANCY_REFUND_MISMATCH, order.RefundedAmount, detail.AmountDetail.RefundAmount); !ok {
+		discrepancies = append(discrepancies, d)
+	}
+
+	return discrepancies
+}
+
+func compareAmount(base Discrepancy, kind string, local int64, remote dana.Amount) (Discrepancy, bool) {
+	value := strings.TrimSpace(remote.Value)
+	if value == "" {
+		value = "0"
+	}
+
+	remoteValue, err := strconv.ParseInt(value, 10, 64)
+	if err == nil && remoteValue == local {
+		return base, true
+	}
+
+	base.Type = kind
+	base.Local = strconv.FormatInt(local, 10)
+	base.Remote = remote.Value
+	if err != nil {
+		base.Err = fmt.Errorf("invalid amount %q from dana", remote.Value)
+	}
+	return base, false
+}
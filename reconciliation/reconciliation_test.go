@@ -0,0 +1,147 @@
+package reconciliation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticSource []LocalOrder
+
+func (s staticSource) Orders(ctx context.Context) ([]LocalOrder, error) {
+	return s, nil
+}
+
+type fakeQuerier struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	orders  map[string]dana.OrderDetailData
+}
+
+func (f *fakeQuerier) OrderDetail(ctx context.Context, reqBody *dana.OrderDetailRequestData, accessToken string) (res dana.OrderDetailResponse, err error) {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.peak {
+		f.peak = f.running
+	}
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+	}()
+
+	if reqBody.MerchantTransID == "broken" {
+		return res, errors.New("connection reset")
+	}
+
+	detail, ok := f.orders[reqBody.MerchantTransID]
+	if !ok {
+		detail.ResultInfo = dana.ResultInfo{ResultStatus: "F", ResultCode: RESULT_CODE_ORDER_NOT_EXIST}
+	}
+	res.Response.Body = detail
+	return res, nil
+}
+
+func paidOrder(amount, refunded string) dana.OrderDetailData {
+	return dana.OrderDetailData{
+		ResultInfo:   dana.ResultInfo{ResultStatus: RESULT_STATUS_SUCCESS},
+		StatusDetail: dana.StatusDetail{AcquirementStatus: "SUCCESS"},
+		AmountDetail: dana.AmountDetail{
+			OrderAmount:  dana.Amount{Currency: "IDR", Value: amount},
+			RefundAmount: dana.Amount{Currency: "IDR", Value: refunded},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	querier := &fakeQuerier{orders: map[string]dana.OrderDetailData{
+		"ok":       paidOrder("1500000", ""),
+		"status":   paidOrder("1500000", ""),
+		"amount":   paidOrder("1000000", ""),
+		"refunded": paidOrder("1500000", "500000"),
+	}}
+
+	source := staticSource{
+		{MerchantTransID: "ok", Status: "SUCCESS", Amount: 1500000},
+		{MerchantTransID: "status", Status: "INIT", Amount: 1500000},
+		{MerchantTransID: "amount", Status: "SUCCESS", Amount: 1500000},
+		{MerchantTransID: "refunded", Status: "SUCCESS", Amount: 1500000},
+		{MerchantTransID: "unknown", Status: "SUCCESS", Amount: 1500000},
+		{MerchantTransID: "broken", Status: "SUCCESS", Amount: 1500000},
+	}
+
+	reconciler := Reconciler{Gateway: querier, MerchantID: "merchant", Concurrency: 2}
+	report, err := reconciler.Run(context.Background(), source)
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, report.Checked)
+	assert.Equal(t, 1, report.Matched)
+	assert.LessOrEqual(t, querier.peak, 2)
+
+	var kinds []string
+	for _, d := range report.Discrepancies {
+		kinds = append(kinds, d.MerchantTransID+":"+d.Type)
+	}
+	assert.Equal(t, []string{
+		"status:" + DISCREPANCY_STATUS_MISMATCH,
+		"amount:" + DISCREPANCY_AMOUNT_MISMATCH,
+		"refunded:" + DISCREPANCY_REFUND_MISMATCH,
+		"unknown:" + DISCREPANCY_MISSING,
+		"broken:" + DISCREPANCY_QUERY_FAILED,
+	}, kinds)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	assert.Contains(t, buf.String(), "STATUS_MISMATCH,status,,INIT,SUCCESS,")
+}
+
+func TestRunCancelsQueriesInFlight(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	private, _, err := dana.GenerateKeyPair(dana.DEFAULT_KEY_BITS)
+	require.NoError(t, err)
+
+	client := dana.NewClient()
+	client.BaseUrl = server.URL
+	client.LogLevel = 0
+	client.PrivateKey = private
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	reconciler := Reconciler{Gateway: GatewayQuerier{Gateway: &dana.CoreGateway{Client: client}}, MerchantID: "merchant"}
+	done := make(chan Report)
+	go func() {
+		report, _ := reconciler.Run(ctx, staticSource{{MerchantTransID: "slow", Status: "SUCCESS"}})
+		done <- report
+	}()
+
+	select {
+	case report := <-done:
+		require.Len(t, report.Discrepancies, 1)
+		assert.Equal(t, DISCREPANCY_QUERY_FAILED, report.Discrepancies[0].Type)
+		assert.True(t, errors.Is(report.Discrepancies[0].Err, context.Canceled), "got %v", report.Discrepancies[0].Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run kept waiting for the query after ctx was cancelled")
+	}
+}
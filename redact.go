@@ -0,0 +1,127 @@
+package dana
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const REDACTED_MASK = "[REDACTED]"
+
+// DEFAULT_REDACTED_FIELDS are the JSON keys masked by NewRedactor: credentials, signatures and user PII
+var DEFAULT_REDACTED_FIELDS = []string{
+	"clientSecret",
+	"accessToken",
+	"refreshToken",
+	"authCode",
+	"signature",
+	"USER_CONTACTINFO",
+	"USER_CONTACTINFO_EMAIL",
+	"USER_ADDRESS",
+	"address1",
+	"address2",
+}
+
+// DEFAULT_REDACTED_HEADERS are the HTTP headers masked by NewRedactor
+var DEFAULT_REDACTED_HEADERS = []string{
+	"Authorization",
+	"Signature",
+	SNAP_HEADER_SIGNATURE,
+}
+
+// Redactor masks sensitive values before a request or response is logged. Fields are JSON keys matched
+// case-insensitively at any depth, and their whole value is masked, including objects and arrays.
+type Redactor struct {
+	Fields  []string
+	Headers []string
+}
+
+// NewRedactor : create a Redactor masking DEFAULT_REDACTED_FIELDS and DEFAULT_REDACTED_HEADERS
+func NewRedactor() *Redactor {
+	return &Redactor{
+		Fields:  append([]string(nil), DEFAULT_REDACTED_FIELDS...),
+		Headers: append([]string(nil), DEFAULT_REDACTED_HEADERS...),
+	}
+}
+
+// JSON : return body with the value of every redacted field masked. Bodies that are not JSON are returned as they are.
+func (r *Redactor) JSON(body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return body
+	}
+
+	redacted, err := json.Marshal(r.redactValue(v, r.fieldSet()))
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// Request : return a copy of req with redacted headers and body, leaving req itself untouched
+func (r *Redactor) Request(req *http.Request) *http.Request {
+	if r == nil {
+		return req
+	}
+
+	clone := req.Clone(req.Context())
+	for _, name := range r.Headers {
+		if clone.Header.Get(name) != "" {
+			clone.Header.Set(name, REDACTED_MASK)
+		}
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return clone
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	redacted := r.JSON(body)
+	clone.Body = ioutil.NopCloser(bytes.NewReader(redacted))
+	clone.ContentLength = int64(len(redacted))
+
+	return clone
+}
+
+func (r *Redactor) fieldSet() map[string]bool {
+	fields := make(map[string]bool, len(r.Fields))
+	for _, name := range r.Fields {
+		fields[strings.ToLower(name)] = true
+	}
+	return fields
+}
+
+func (r *Redactor) redactValue(v interface{}, fields map[string]bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if fields[strings.ToLower(key)] {
+				t[key] = REDACTED_MASK
+				continue
+			}
+			t[key] = r.redactValue(value, fields)
+		}
+		return t
+	case []interface{}:
+		for i, value := range t {
+			t[i] = r.redactValue(value, fields)
+		}
+		return t
+	default:
+		return v
+	}
+}
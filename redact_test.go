@@ -0,0 +1,51 @@
+package dana
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"moul.io/http2curl"
+)
+
+func TestRedactorJSON(t *testing.T) {
+	body := []byte(`{"request":{"head":{"clientId":"c","clientSecret":"s3cret","accessToken":"tok"},"body":{"amount":1500000}},"signature":"sig",` +
+		`"userInfo":{"USER_NAME":"Budi","USER_CONTACTINFO_EMAIL":"budi@example.com","USER_ADDRESS":[{"city":"Jakarta"}]}}`)
+
+	redacted := string(NewRedactor().JSON(body))
+
+	for _, secret := range []string{"s3cret", "tok", "sig", "budi@example.com", "Jakarta"} {
+		assert.NotContains(t, redacted, `"`+secret+`"`)
+	}
+	assert.Contains(t, redacted, `"clientId":"c"`)
+	assert.Contains(t, redacted, `"amount":1500000`)
+	assert.Contains(t, redacted, `"USER_NAME":"Budi"`)
+
+	assert.Equal(t, "not json", string(NewRedactor().JSON([]byte("not json"))))
+
+	var disabled *Redactor
+	assert.Equal(t, string(body), string(disabled.JSON(body)))
+}
+
+func TestRedactorRequest(t *testing.T) {
+	body := []byte(`{"accessToken":"tok","extendInfo":""}`)
+	req, err := http.NewRequest("POST", "http://dana.test/v1/path", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Signature", "sig")
+	req.Header.Set("Client-Id", "client")
+
+	command, err := http2curl.GetCurlCommand(NewRedactor().Request(req))
+	require.NoError(t, err)
+	assert.NotContains(t, command.String(), "tok")
+	assert.NotContains(t, command.String(), "sig")
+	assert.Contains(t, command.String(), "client")
+
+	// the request that is actually sent is left untouched
+	assert.Equal(t, "sig", req.Header.Get("Signature"))
+	sent, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, sent)
+}
@@ -0,0 +1,334 @@
+// Package replay records the exchanges between a dana.Client and DANA into fixture files, and replays
+// them later without a network or credentials, e.g. in CI.
+//
+// Record once against the sandbox:
+//
+//	recorder := replay.NewRecorder("testdata/order.json", nil)
+//	client.HTTPClient = &http.Client{Transport: recorder}
+//
+// and replay in tests:
+//
+//	replayer, err := replay.Load("testdata/order.json")
+//	client.HTTPClient = &http.Client{Transport: replayer}
+//	client.SignatureEnabled = false
+//
+// Fixtures are redacted, which breaks DANA's response signatures, so replaying clients must not verify them.
+// Requests are matched by method, path, DANA function and the key fields of their body, so the reqTime,
+// reqMsgId and signature that change on every request don't prevent a match.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/tidwall/gjson"
+)
+
+// DEFAULT_MATCH_FIELDS are the body fields a replayed request must agree on with the recorded one.
+// They are looked up in the signed envelope body first, then at the top level of the body.
+var DEFAULT_MATCH_FIELDS = []string{
+	"merchantTransId",
+	"order.merchantTransId",
+	"acquirementId",
+	"requestId",
+	"refundId",
+	"customerNumber",
+	"partnerReferenceNo",
+	"originalPartnerReferenceNo",
+}
+
+// Cassette is the content of a fixture file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and the response DANA gave
+type Interaction struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Function string            `json:"function,omitempty"`
+	Keys     map[string]string `json:"keys,omitempty"`
+	Request  Message           `json:"request"`
+	Response Message           `json:"response"`
+}
+
+// Message is a redacted request or response. JSON bodies are kept as JSON to keep fixtures readable,
+// any other body is kept in Text.
+type Message struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	Text    string            `json:"text,omitempty"`
+}
+
+// Recorder is an http.RoundTripper sending requests through Transport and saving every exchange to Path
+type Recorder struct {
+	Transport   http.RoundTripper
+	Path        string
+	Redactor    *dana.Redactor
+	MatchFields []string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder : record into path the exchanges sent through transport, http.DefaultTransport when nil
+func NewRecorder(path string, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Recorder{
+		Transport:   transport,
+		Path:        path,
+		Redactor:    dana.NewRedactor(),
+		MatchFields: DEFAULT_MATCH_FIELDS,
+	}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// req belongs to the caller, a clone carrying the body that was read is sent in its place
+	out := req.Clone(req.Context())
+	if reqBody != nil {
+		out.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(reqBody)), nil
+		}
+	}
+
+	res, err := r.Transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	interaction := Interaction{
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		Function: function(reqBody),
+		Keys:     keys(reqBody, r.MatchFields),
+		Request: Message{
+			Headers: r.headers(req.Header),
+		},
+		Response: Message{
+			Status:  res.StatusCode,
+			Headers: map[string]string{"Content-Type": res.Header.Get("Content-Type")},
+		},
+	}
+	interaction.Request.setBody(r.Redactor.JSON(reqBody))
+	interaction.Response.setBody(r.Redactor.JSON(resBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err = r.save(); err != nil {
+		return nil, fmt.Errorf("replay: cannot save %s: %v", r.Path, err)
+	}
+
+	return res, nil
+}
+
+func (r *Recorder) save() error {
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(r.Path, append(data, '\n'), 0644)
+}
+
+func (r *Recorder) headers(header http.Header) map[string]string {
+	redacted := r.Redactor.Request(&http.Request{Header: header.Clone()})
+
+	headers := make(map[string]string, len(header))
+	for name := range redacted.Header {
+		headers[name] = redacted.Header.Get(name)
+	}
+
+	return headers
+}
+
+// Replayer is an http.RoundTripper answering requests with the responses of a fixture file
+type Replayer struct {
+	MatchFields []string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// Load : replay the exchanges recorded in path
+func Load(path string) (*Replayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: cannot read %s: %v", path, err)
+	}
+
+	r := &Replayer{MatchFields: DEFAULT_MATCH_FIELDS}
+	if err = json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("replay: invalid fixture %s: %v", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+
+	return r, nil
+}
+
+// RoundTrip answers with the first recorded interaction matching req that wasn't replayed yet. Once all
+// matching interactions are replayed the last one is repeated, so retries still get an answer.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fn := function(reqBody)
+	reqKeys := keys(reqBody, r.MatchFields)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for i, interaction := range r.cassette.Interactions {
+		if interaction.Method != req.Method || interaction.Path != req.URL.Path || interaction.Function != fn {
+			continue
+		}
+
+		if !sameKeys(interaction.Keys, reqKeys) {
+			continue
+		}
+
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+
+	if match < 0 {
+		return nil, fmt.Errorf("replay: no recorded interaction for %s %s function=%q keys=%v", req.Method, req.URL.Path, fn, reqKeys)
+	}
+	r.used[match] = true
+
+	recorded := r.cassette.Interactions[match].Response
+	body := recorded.body()
+
+	header := http.Header{}
+	for name, value := range recorded.Headers {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (m *Message) setBody(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+
+	if json.Valid(body) {
+		m.Body = json.RawMessage(body)
+		return
+	}
+
+	m.Text = string(body)
+}
+
+func (m Message) body() []byte {
+	if len(m.Body) > 0 {
+		return m.Body
+	}
+
+	return []byte(m.Text)
+}
+
+// readRequestBody reads the body of req without modifying req: from a copy returned by GetBody when it is
+// set, from req.Body otherwise. req.Body is closed either way, as a transport does.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+
+	body := req.Body
+	if req.GetBody != nil {
+		copied, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("replay: cannot copy request body: %v", err)
+		}
+		defer copied.Close()
+		body = copied
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("replay: cannot read request body: %v", err)
+	}
+
+	return data, nil
+}
+
+// function is the DANA function of a signed envelope, empty for V1 and SNAP requests
+func function(body []byte) string {
+	return gjson.GetBytes(body, "request.head.function").String()
+}
+
+func keys(body []byte, fields []string) map[string]string {
+	found := map[string]string{}
+	for _, field := range fields {
+		for _, path := range []string{"request.body." + field, field} {
+			if value := gjson.GetBytes(body, path); value.Exists() {
+				found[field] = value.String()
+				break
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	return found
+}
+
+func sameKeys(recorded, req map[string]string) bool {
+	if len(recorded) != len(req) {
+		return false
+	}
+
+	for field, value := range recorded {
+		if req[field] != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package replay
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+var testPrivateKey = func() []byte {
+	private, _, err := dana.GenerateKeyPair(dana.DEFAULT_KEY_BITS)
+	if err != nil {
+		panic(err)
+	}
+
+	return private
+}()
+
+func newGateway(transport http.RoundTripper, baseURL string) dana.CoreGateway {
+	client := dana.NewClient()
+	client.LogLevel = 0
+	client.BaseUrl = baseURL
+	client.ClientId = "client-id"
+	client.ClientSecret = "client-secret"
+	client.PrivateKey = testPrivateKey
+	client.SignatureEnabled = false
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	return dana.CoreGateway{Client: client}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "order_detail.json")
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		acquirementID := gjson.GetBytes(body, "request.body.acquirementId").String()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"response": map[string]interface{}{
+				"head": map[string]string{"function": dana.FUNCTION_QUERY_ORDER, "accessToken": "user-token"},
+				"body": map[string]interface{}{
+					"resultInfo":    map[string]string{"resultStatus": "S"},
+					"acquirementId": acquirementID,
+					"statusDetail":  map[string]string{"acquirementStatus": "SUCCESS"},
+				},
+			},
+			"signature": "dana-signature",
+		})
+	}))
+
+	recording := newGateway(NewRecorder(fixture, nil), server.URL)
+	for _, id := range []string{"acq-1", "acq-2"} {
+		_, err = recording.OrderDetail(&dana.OrderDetailRequestData{MerchantID: "m", AcquirementID: id}, "")
+		require.NoError(t, err)
+	}
+	server.Close()
+
+	data, err := ioutil.ReadFile(fixture)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "client-secret")
+	assert.NotContains(t, string(data), "user-token")
+	assert.NotContains(t, string(data), "dana-signature")
+	assert.Contains(t, string(data), dana.REDACTED_MASK)
+
+	replayer, err := Load(fixture)
+	require.NoError(t, err)
+	replaying := newGateway(replayer, "http://dana.invalid")
+
+	// the reqTime, reqMsgId and signature differ from the recording
+	res, err := replaying.OrderDetail(&dana.OrderDetailRequestData{MerchantID: "m", AcquirementID: "acq-2"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "acq-2", res.Response.Body.AcquirementID)
+	assert.Equal(t, dana.ACQUIREMENT_STATUS_SUCCESS, res.Response.Body.StatusDetail.AcquirementStatus)
+
+	res, err = replaying.OrderDetail(&dana.OrderDetailRequestData{MerchantID: "m", AcquirementID: "acq-1"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "acq-1", res.Response.Body.AcquirementID)
+
+	// a retry gets the same answer again
+	_, err = replaying.OrderDetail(&dana.OrderDetailRequestData{MerchantID: "m", AcquirementID: "acq-1"}, "")
+	require.NoError(t, err)
+
+	_, err = replaying.OrderDetail(&dana.OrderDetailRequestData{MerchantID: "m", AcquirementID: "acq-3"}, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no recorded interaction")
+	assert.Equal(t, 2, calls)
+}
+
+func TestRecorderDoesNotModifyTheRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest("POST", server.URL+"/dana/query", strings.NewReader(`{"merchantTransId":"ORDER-1"}`))
+	require.NoError(t, err)
+	body, getBody := req.Body, req.GetBody
+
+	res, err := NewRecorder(filepath.Join(dir, "fixture.json"), nil).RoundTrip(req)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	assert.Equal(t, `{"merchantTransId":"ORDER-1"}`, received)
+	assert.True(t, req.Body == body, "the caller's body must not be replaced")
+	assert.Equal(t, reflect.ValueOf(getBody).Pointer(), reflect.ValueOf(req.GetBody).Pointer())
+
+	again, err := req.GetBody()
+	require.NoError(t, err)
+	data, _ := ioutil.ReadAll(again)
+	assert.Equal(t, `{"merchantTransId":"ORDER-1"}`, string(data))
+}
+
+func TestLoadMissingFixture(t *testing.T) {
+	_, err := Load(filepath.Join("testdata", "missing.json"))
+	assert.Error(t, err)
+}
@@ -1,7 +1,5 @@
 package dana
 
-import "time"
-
 type RequestBody struct {
 	Request   Request `json:"request" valid:"required"`
 	Signature string  `json:"signature" valid:"required"`
@@ -30,7 +28,7 @@ type OrderRequestData struct {
 	ProductCode       string             `json:"productCode" valid:"required"`
 	EnvInfo           EnvInfo            `json:"envInfo" valid:"required"`
 	NotificationUrls  *[]NotificationUrl `json:"notificationUrls,omitempty" valid:"optional"`
-	ExtendInfo        string             `json:"extendInfo,omitempty" valid:"optional"`
+	ExtendInfo        ExtendInfo         `json:"extendInfo,omitempty" valid:"optional"`
 	PaymentPreference *PaymentPreference `json:"paymentPreference,omitempty" valid:"optional"`
 }
 
@@ -41,19 +39,19 @@ type OrderDetailRequestData struct {
 }
 
 type RefundRequestData struct {
-	RequestID           string       `json:"requestId" valid:"required"`
-	MerchantID          string       `json:"merchantId" valid:"required"`
-	AcquirementID       string       `json:"acquirementId,omitempty" valid:"optional"`
-	RefundAmount        Amount       `json:"refundAmount,omitempty" valid:"required"`
-	RefundAppliedTime   time.Time    `json:"refundAppliedTime,omitempty" valid:"optional"`
-	ActorType           string       `json:"actorType,omitempty" valid:"optional"`
-	RefundReason        string       `json:"refundReason,omitempty" valid:"optional"`
-	ReturnChargeToPayer bool         `json:"returnChargeToPayer,omitempty" valid:"optional"`
-	Destination         string       `json:"destination,omitempty" valid:"optional"`
-	ExtendInfo          string       `json:"extendInfo,omitempty" valid:"optional"`
-	EnvInfo             EnvInfo      `json:"envInfo,omitempty" valid:"optional"`
-	AuditInfo           AuditInfo    `json:"auditInfo,omitempty" valid:"optional"`
-	ActorContext        ActorContext `json:"actorContext,omitempty" valid:"optional"`
+	RequestID           string                `json:"requestId" valid:"required"`
+	MerchantID          string                `json:"merchantId" valid:"required"`
+	AcquirementID       string                `json:"acquirementId,omitempty" valid:"optional"`
+	RefundAmount        Amount                `json:"refundAmount,omitempty" valid:"required"`
+	RefundAppliedTime   *DanaTime             `json:"refundAppliedTime,omitempty" valid:"optional"`
+	ActorType           ActorTypeEnum         `json:"actorType,omitempty" valid:"optional"`
+	RefundReason        string                `json:"refundReason,omitempty" valid:"optional"`
+	ReturnChargeToPayer bool                  `json:"returnChargeToPayer,omitempty" valid:"optional"`
+	Destination         RefundDestinationEnum `json:"destination,omitempty" valid:"optional"`
+	ExtendInfo          ExtendInfo            `json:"extendInfo,omitempty" valid:"optional"`
+	EnvInfo             EnvInfo               `json:"envInfo,omitempty" valid:"optional"`
+	AuditInfo           AuditInfo             `json:"auditInfo,omitempty" valid:"optional"`
+	ActorContext        ActorContext          `json:"actorContext,omitempty" valid:"optional"`
 }
 
 type Order struct {
@@ -62,8 +60,8 @@ type Order struct {
 	MerchantTransID   string         `json:"merchantTransId"`
 	MerchantTransType string         `json:"merchantTransType,omitempty"`
 	OrderMemo         string         `json:"orderMemo,omitempty"`
-	CreatedTime       string         `json:"createdTime,omitempty"`
-	ExpiryTime        string         `json:"expiryTime,omitempty"`
+	CreatedTime       *DanaTime      `json:"createdTime,omitempty"`
+	ExpiryTime        *DanaTime      `json:"expiryTime,omitempty"`
 	Goods             []Good         `json:"goods,omitempty"`
 	ShippingInfo      []ShippingInfo `json:"shippingInfo,omitempty"`
 }
@@ -74,15 +72,15 @@ type Amount struct {
 }
 
 type Good struct {
-	MerchantGoodsID    string `json:"merchantGoodsId,omitempty"`
-	Description        string `json:"description"`
-	Category           string `json:"category,omitempty"`
-	Price              Amount `json:"price"`
-	Unit               string `json:"unit,omitempty"`
-	Quantity           string `json:"quantity,omitempty"`
-	MerchantShippingID string `json:"merchantShippingId,omitempty"`
-	SnapshotURL        string `json:"snapshotUrl,omitempty"`
-	ExtendInfo         string `json:"extendInfo,omitempty"`
+	MerchantGoodsID    string     `json:"merchantGoodsId,omitempty"`
+	Description        string     `json:"description"`
+	Category           string     `json:"category,omitempty"`
+	Price              Amount     `json:"price"`
+	Unit               string     `json:"unit,omitempty"`
+	Quantity           string     `json:"quantity,omitempty"`
+	MerchantShippingID string     `json:"merchantShippingId,omitempty"`
+	SnapshotURL        string     `json:"snapshotUrl,omitempty"`
+	ExtendInfo         ExtendInfo `json:"extendInfo,omitempty"`
 }
 
 type ShippingInfo struct {
@@ -106,20 +104,20 @@ type ShippingInfo struct {
 }
 
 type EnvInfo struct {
-	SessionID          string `json:"sessionId,omitempty"`
-	TokenID            string `json:"tokenId,omitempty"`
-	WebsiteLanguage    string `json:"websiteLanguage,omitempty"`
-	ClientIP           string `json:"clientIp,omitempty"`
-	OsType             string `json:"osType,omitempty"`
-	AppVersion         string `json:"appVersion,omitempty"`
-	SdkVersion         string `json:"sdkVersion,omitempty"`
-	SourcePlatform     string `json:"sourcePlatform"`
-	TerminalType       string `json:"terminalType"`
-	ClientKey          string `json:"clientKey,omitempty"`
-	OrderTerminalType  string `json:"orderTerminalType"`
-	OrderOsType        string `json:"orderOsType,omitempty"`
-	MerchantAppVersion string `json:"merchantAppVersion,omitempty"`
-	ExtendInfo         string `json:"extendInfo,omitempty"`
+	SessionID          string     `json:"sessionId,omitempty"`
+	TokenID            string     `json:"tokenId,omitempty"`
+	WebsiteLanguage    string     `json:"websiteLanguage,omitempty"`
+	ClientIP           string     `json:"clientIp,omitempty"`
+	OsType             string     `json:"osType,omitempty"`
+	AppVersion         string     `json:"appVersion,omitempty"`
+	SdkVersion         string     `json:"sdkVersion,omitempty"`
+	SourcePlatform     string     `json:"sourcePlatform"`
+	TerminalType       string     `json:"terminalType"`
+	ClientKey          string     `json:"clientKey,omitempty"`
+	OrderTerminalType  string     `json:"orderTerminalType"`
+	OrderOsType        string     `json:"orderOsType,omitempty"`
+	MerchantAppVersion string     `json:"merchantAppVersion,omitempty"`
+	ExtendInfo         ExtendInfo `json:"extendInfo,omitempty"`
 }
 
 type AuditInfo struct {
@@ -128,8 +126,8 @@ type AuditInfo struct {
 }
 
 type ActorContext struct {
-	ActorID   string `json:"actorId" valid:"required"`
-	ActorType string `json:"actorType" valid:"required"`
+	ActorID   string        `json:"actorId" valid:"required"`
+	ActorType ActorTypeEnum `json:"actorType,omitempty" valid:"required"`
 }
 
 type NotificationUrl struct {
@@ -138,7 +136,7 @@ type NotificationUrl struct {
 }
 
 type PaymentPreference struct {
-	DisabledPayMethods string `json:"disabledPayMethods"`
+	DisabledPayMethods PayMethods `json:"disabledPayMethods"`
 }
 
 type PayFinishRequest struct {
@@ -151,14 +149,14 @@ type RequestPayFinish struct {
 }
 
 type RequestBodyPayFinish struct {
-	AcquirementID     string `json:"acquirementId"`
-	MerchantTransID   string `json:"merchantTransId"`
-	FinishedTime      string `json:"finishedTime"`
-	CreatedTime       string `json:"createdTime"`
-	MerchantID        string `json:"merchantId"`
-	OrderAmount       Amount `json:"orderAmount"`
-	AcquirementStatus string `json:"acquirementStatus"`
-	ExtendInfo        string `json:"extendInfo"`
+	AcquirementID     string            `json:"acquirementId"`
+	MerchantTransID   string            `json:"merchantTransId"`
+	FinishedTime      DanaTime          `json:"finishedTime"`
+	CreatedTime       DanaTime          `json:"createdTime"`
+	MerchantID        string            `json:"merchantId"`
+	OrderAmount       Amount            `json:"orderAmount"`
+	AcquirementStatus AcquirementStatus `json:"acquirementStatus"`
+	ExtendInfo        ExtendInfo        `json:"extendInfo"`
 }
 
 type RequestApplyAccessToken struct {
@@ -172,6 +170,29 @@ type UserProfileRequestData struct {
 }
 
 type InquiryUserInfoRequest struct {
-	AccessToken string `json:"accessToken" valid:"required"`
-	ExtendInfo  string `json:"extendInfo,omitempty" valid:"optional"`
+	AccessToken string     `json:"accessToken" valid:"required"`
+	ExtendInfo  ExtendInfo `json:"extendInfo,omitempty" valid:"optional"`
+}
+
+type TransferInquiryRequestData struct {
+	RequestID      string     `json:"requestId" valid:"required"`
+	MerchantID     string     `json:"merchantId" valid:"required"`
+	CustomerNumber string     `json:"customerNumber" valid:"required"`
+	Amount         Amount     `json:"amount" valid:"required"`
+	ExtendInfo     ExtendInfo `json:"extendInfo,omitempty" valid:"optional"`
+}
+
+type TransferRequestData struct {
+	RequestID      string     `json:"requestId" valid:"required"`
+	MerchantID     string     `json:"merchantId" valid:"required"`
+	CustomerNumber string     `json:"customerNumber" valid:"required"`
+	Amount         Amount     `json:"amount" valid:"required"`
+	Remark         string     `json:"remark,omitempty" valid:"optional"`
+	ExtendInfo     ExtendInfo `json:"extendInfo,omitempty" valid:"optional"`
+}
+
+type TransferQueryRequestData struct {
+	MerchantID string `json:"merchantId" valid:"required"`
+	RequestID  string `json:"requestId" valid:"required"`
+	TransferID string `json:"transferId,omitempty" valid:"optional"`
 }
@@ -10,6 +10,103 @@ type Response struct {
 	Body interface{}    `json:"body" valid:"required"`
 }
 
+// RawBody keeps the response body exactly as DANA sent it, for auditing. It is embedded in every typed response.
+type RawBody struct {
+	Raw []byte `json:"-"`
+}
+
+func (r *RawBody) setRawBody(body []byte) {
+	r.Raw = body
+}
+
+type OrderResponse struct {
+	RawBody
+	Response  OrderResponseContent `json:"response" valid:"required"`
+	Signature string               `json:"signature" valid:"required"`
+}
+
+type OrderResponseContent struct {
+	Head ResponseHeader    `json:"head" valid:"required"`
+	Body OrderResponseData `json:"body" valid:"required"`
+}
+
+type OrderDetailResponse struct {
+	RawBody
+	Response  OrderDetailResponseContent `json:"response" valid:"required"`
+	Signature string                     `json:"signature" valid:"required"`
+}
+
+type OrderDetailResponseContent struct {
+	Head ResponseHeader  `json:"head" valid:"required"`
+	Body OrderDetailData `json:"body" valid:"required"`
+}
+
+type ApplyAccessTokenResponse struct {
+	RawBody
+	Response  ApplyAccessTokenResponseContent `json:"response" valid:"required"`
+	Signature string                          `json:"signature" valid:"required"`
+}
+
+type ApplyAccessTokenResponseContent struct {
+	Head ResponseHeader   `json:"head" valid:"required"`
+	Body ApplyAccessToken `json:"body" valid:"required"`
+}
+
+type RefundResponse struct {
+	RawBody
+	Response  RefundResponseContent `json:"response" valid:"required"`
+	Signature string                `json:"signature" valid:"required"`
+}
+
+type RefundResponseContent struct {
+	Head ResponseHeader     `json:"head" valid:"required"`
+	Body RefundResponseData `json:"body" valid:"required"`
+}
+
+type UserProfileResponse struct {
+	RawBody
+	Response  UserProfileResponseContent `json:"response" valid:"required"`
+	Signature string                     `json:"signature" valid:"required"`
+}
+
+type UserProfileResponseContent struct {
+	Head ResponseHeader          `json:"head" valid:"required"`
+	Body UserProfileResponseData `json:"body" valid:"required"`
+}
+
+type TransferInquiryResponse struct {
+	RawBody
+	Response  TransferInquiryResponseContent `json:"response" valid:"required"`
+	Signature string                         `json:"signature" valid:"required"`
+}
+
+type TransferInquiryResponseContent struct {
+	Head ResponseHeader              `json:"head" valid:"required"`
+	Body TransferInquiryResponseData `json:"body" valid:"required"`
+}
+
+type TransferResponse struct {
+	RawBody
+	Response  TransferResponseContent `json:"response" valid:"required"`
+	Signature string                  `json:"signature" valid:"required"`
+}
+
+type TransferResponseContent struct {
+	Head ResponseHeader       `json:"head" valid:"required"`
+	Body TransferResponseData `json:"body" valid:"required"`
+}
+
+type TransferQueryResponse struct {
+	RawBody
+	Response  TransferQueryResponseContent `json:"response" valid:"required"`
+	Signature string                       `json:"signature" valid:"required"`
+}
+
+type TransferQueryResponseContent struct {
+	Head ResponseHeader            `json:"head" valid:"required"`
+	Body TransferQueryResponseData `json:"body" valid:"required"`
+}
+
 type ResponseHeader struct {
 	Function  string `json:"function" valid:"required"`
 	ClientID  string `json:"clientId" valid:"required"`
@@ -32,7 +129,7 @@ type OrderDetailData struct {
 	Buyer           InputUserInfo  `json:"buyer" valid:"optional"`
 	Seller          InputUserInfo  `json:"seller" valid:"optional"`
 	OrderTitle      string         `json:"orderTitle" valid:"optional"`
-	ExtendedInfo    string         `json:"extendedInfo" valid:"optional"`
+	ExtendedInfo    ExtendInfo     `json:"extendedInfo" valid:"optional"`
 	AmountDetail    AmountDetail   `json:"amountDetail" valid:"optional"`
 	TimeDetail      TimeDetail     `json:"timeDetail" valid:"optional"`
 	StatusDetail    StatusDetail   `json:"statusDetail" valid:"optional"`
@@ -88,33 +185,33 @@ type AmountDetail struct {
 }
 
 type TimeDetail struct {
-	CreatedTime    string   `json:"createdTime" valid:"required"`
-	ExpiryTime     string   `json:"expiryTime" valid:"required"`
-	PaidTimes      []string `json:"paidTimes" valid:"optional"`
-	ConfirmedTimes []string `json:"confirmedTimes" valid:"optional"`
-	CancelledTime  string   `json:"cancelledTime" valid:"optional"`
+	CreatedTime    DanaTime   `json:"createdTime" valid:"required"`
+	ExpiryTime     DanaTime   `json:"expiryTime" valid:"required"`
+	PaidTimes      []DanaTime `json:"paidTimes" valid:"optional"`
+	ConfirmedTimes []DanaTime `json:"confirmedTimes" valid:"optional"`
+	CancelledTime  DanaTime   `json:"cancelledTime" valid:"optional"`
 }
 
 type StatusDetail struct {
-	AcquirementStatus string `json:"acquirementStatus" valid:"required"`
-	Frozen            bool   `json:"frozen" valid:"required"`
+	AcquirementStatus AcquirementStatus `json:"acquirementStatus" valid:"required"`
+	Frozen            bool              `json:"frozen" valid:"required"`
 }
 
 type PaymentView struct {
 	CashierRequestID     string          `json:"cashierRequestId" valid:"required"`
-	PaidTime             string          `json:"paidTime" valid:"required"`
+	PaidTime             DanaTime        `json:"paidTime" valid:"required"`
 	PayOptionInfos       []PayOptionInfo `json:"payOptionInfos" valid:"required"`
-	PayRequestExtendInfo string          `json:"payRequestExtendInfo" valid:"optional"`
-	ExtendInfo           string          `json:"extendInfo" valid:"optional"`
+	PayRequestExtendInfo ExtendInfo      `json:"payRequestExtendInfo" valid:"optional"`
+	ExtendInfo           ExtendInfo      `json:"extendInfo" valid:"optional"`
 }
 
 type PayOptionInfo struct {
-	PayMethod               string `json:"payMethod" valid:"required"`
-	PayAmount               Amount `json:"payAmount" valid:"required"`
-	TransAmount             Amount `json:"transAmount" valid:"optional"`
-	ChargeAmount            Amount `json:"chargeAmount" valid:"optional"`
-	ExtendInfo              string `json:"extendInfo" valid:"optional"`
-	PayOptionBillExtendInfo string `json:"payOptionBillExtendInfo" valid:"optional"`
+	PayMethod               string     `json:"payMethod" valid:"required"`
+	PayAmount               Amount     `json:"payAmount" valid:"required"`
+	TransAmount             Amount     `json:"transAmount" valid:"optional"`
+	ChargeAmount            Amount     `json:"chargeAmount" valid:"optional"`
+	ExtendInfo              ExtendInfo `json:"extendInfo" valid:"optional"`
+	PayOptionBillExtendInfo ExtendInfo `json:"payOptionBillExtendInfo" valid:"optional"`
 }
 
 type AccessTokenInfo struct {
@@ -145,6 +242,7 @@ type UserProfileResponseData struct {
 }
 
 type InquiryUserInfoResponse struct {
+	RawBody
 	Result   ResultInfo     `json:"result" valid:"required"`
 	UserInfo ResultUserInfo `json:"userInfo"  valid:"required"`
 }
@@ -162,3 +260,32 @@ type UserAddress struct {
 	City     string `json:"city" valid:"optional"`
 	Address1 string `json:"address1" valid:"optional"`
 }
+
+type TransferInquiryResponseData struct {
+	ResultInfo     ResultInfo `json:"resultInfo" valid:"required"`
+	RequestID      string     `json:"requestId" valid:"optional"`
+	CustomerNumber string     `json:"customerNumber" valid:"optional"`
+	CustomerName   string     `json:"customerName" valid:"optional"`
+	Amount         Amount     `json:"amount" valid:"optional"`
+	FeeAmount      Amount     `json:"feeAmount" valid:"optional"`
+	ExtendInfo     ExtendInfo `json:"extendInfo" valid:"optional"`
+}
+
+type TransferResponseData struct {
+	ResultInfo ResultInfo `json:"resultInfo" valid:"required"`
+	RequestID  string     `json:"requestId" valid:"optional"`
+	TransferID string     `json:"transferId" valid:"optional"`
+	Amount     Amount     `json:"amount" valid:"optional"`
+	ExtendInfo ExtendInfo `json:"extendInfo" valid:"optional"`
+}
+
+type TransferQueryResponseData struct {
+	ResultInfo     ResultInfo `json:"resultInfo" valid:"required"`
+	RequestID      string     `json:"requestId" valid:"optional"`
+	TransferID     string     `json:"transferId" valid:"optional"`
+	TransferStatus string     `json:"transferStatus" valid:"optional"`
+	Amount         Amount     `json:"amount" valid:"optional"`
+	CreatedTime    DanaTime   `json:"createdTime" valid:"optional"`
+	FinishedTime   DanaTime   `json:"finishedTime" valid:"optional"`
+	ExtendInfo     ExtendInfo `json:"extendInfo" valid:"optional"`
+}
@@ -0,0 +1,440 @@
+// Package settlement reads the daily settlement and transaction reports DANA delivers to merchants.
+//
+// A report is a delimited text file made of three parts:
+//
+//	acquirementId,merchantTransId,transactionType,transactionTime,currency,amount,fee,netAmount,status
+//	20201001111212800110166,ORDER-1,PAYMENT,2020-10-01T11:12:12+07:00,IDR,15000.00,300.00,14700.00,SUCCESS
+//	...
+//	TRAILER,1,15000.00,300.00,8c1d4a8a5b2b3f1f7a9e6c0d2e4b6a8c
+//
+// The first line names the columns, so their order does not matter. Only acquirementId,
+// merchantTransId and amount are required; netAmount defaults to amount minus fee. The last line is
+// the trailer holding the number of records, the total amount, the total fee and, optionally, the
+// hex MD5 checksum of the raw bytes before the trailer line, blank lines and line endings included.
+// Fields follow the CSV quoting rules, a quoted field may span several lines.
+//
+// Amounts are written in rupiah and are parsed into minor units, so 15000.00 becomes 1500000.
+package settlement
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	dana "github.com/kitabisa/sangu-dana"
+)
+
+const (
+	COLUMN_ACQUIREMENT_ID    = "acquirementid"
+	COLUMN_MERCHANT_TRANS_ID = "merchanttransid"
+	COLUMN_TRANSACTION_TYPE  = "transactiontype"
+	COLUMN_TRANSACTION_TIME  = "transactiontime"
+	COLUMN_CURRENCY          = "currency"
+	COLUMN_AMOUNT            = "amount"
+	COLUMN_FEE               = "fee"
+	COLUMN_NET_AMOUNT        = "netamount"
+	COLUMN_STATUS            = "status"
+	COLUMN_REFUND_ID         = "refundid"
+
+	TRAILER_MARKER = "TRAILER"
+
+	TRANSACTION_TYPE_PAYMENT = "PAYMENT"
+	TRANSACTION_TYPE_REFUND  = "REFUND"
+)
+
+var (
+	ErrMissingHeader  = errors.New("missing header line")
+	ErrMissingTrailer = errors.New("missing trailer line")
+	ErrAfterTrailer   = errors.New("unexpected data after trailer")
+)
+
+var requiredColumns = []string{COLUMN_ACQUIREMENT_ID, COLUMN_MERCHANT_TRANS_ID, COLUMN_AMOUNT}
+
+// Record is a single transaction line of a settlement report. Amounts are in minor units.
+type Record struct {
+	Line            int
+	AcquirementID   string
+	MerchantTransID string
+	RefundID        string
+	TransactionType string
+	TransactionTime time.Time
+	Currency        string
+	Amount          int64
+	Fee             int64
+	NetAmount       int64
+	Status          string
+}
+
+// Trailer holds the totals DANA declares at the end of a report.
+type Trailer struct {
+	Line        int
+	RecordCount int
+	TotalAmount int64
+	TotalFee    int64
+	Checksum    string
+}
+
+// ParseError reports the line, and column when known, a report could not be read at.
+type ParseError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("settlement: line %d, column %s: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("settlement: line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads records one at a time, so reports of any size can be processed in constant memory.
+// Read returns io.EOF once the trailer has been read and matches every record before it.
+type Reader struct {
+	// Comma is the field delimiter. It is set to ',' by NewReader.
+	Comma rune
+	// TimeLayout is used to parse the transactionTime column. It is set to dana.DANA_TIME_LAYOUT by NewReader.
+	TimeLayout string
+
+	src     *lineSource
+	csv     *csv.Reader
+	line    int
+	columns map[string]int
+	trailer *Trailer
+
+	count       int
+	totalAmount int64
+	totalFee    int64
+}
+
+// NewReader returns a Reader reading a report from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		Comma:      ',',
+		TimeLayout: dana.DANA_TIME_LAYOUT,
+		src:        &lineSource{r: bufio.NewReader(r), checksum: md5.New()},
+	}
+}
+
+// lineSource hands the report to csv.Reader one line per Read. csv.Reader reads through a bufio.Reader
+// that only asks for more input once it holds no complete line, so when a record is returned the last
+// line handed out is the last line of that record. A line is hashed once the next one is handed out,
+// which keeps the trailer line out of the checksum.
+type lineSource struct {
+	r        *bufio.Reader
+	lines    int
+	pending  []byte
+	last     []byte
+	checksum hash.Hash
+}
+
+func (s *lineSource) Read(p []byte) (int, error) {
+	if len(s.pending) == 0 {
+		line, err := s.r.ReadBytes('\n')
+		if len(line) == 0 {
+			return 0, err
+		}
+
+		s.checksum.Write(s.last)
+		s.last = line
+		s.pending = line
+		s.lines++
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Read returns the next record of the report.
+func (r *Reader) Read() (*Record, error) {
+	if r.trailer != nil {
+		return nil, io.EOF
+	}
+
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, err := r.readRecord()
+	if err == io.EOF {
+		return nil, &ParseError{Line: r.line + 1, Err: ErrMissingTrailer}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(strings.TrimSpace(fields[0]), TRAILER_MARKER) {
+		if err := r.readTrailer(fields); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	record, err := r.parseRecord(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	r.count++
+	r.totalAmount += record.Amount
+	r.totalFee += record.Fee
+
+	return record, nil
+}
+
+// ReadAll reads every remaining record of the report.
+func (r *Reader) ReadAll() (records []Record, err error) {
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	}
+}
+
+// Trailer returns the trailer of the report once Read has returned io.EOF, and nil before.
+func (r *Reader) Trailer() *Trailer {
+	return r.trailer
+}
+
+func (r *Reader) readHeader() error {
+	fields, err := r.readRecord()
+	if err == io.EOF {
+		return &ParseError{Line: 1, Err: ErrMissingHeader}
+	}
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]int, len(fields))
+	for i, name := range fields {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return &ParseError{Line: r.line, Column: name, Err: errors.New("required column is missing from header")}
+		}
+	}
+
+	r.columns = columns
+	return nil
+}
+
+func (r *Reader) readTrailer(fields []string) error {
+	if len(fields) < 4 {
+		return &ParseError{Line: r.line, Err: fmt.Errorf("trailer has %d fields, want at least 4", len(fields))}
+	}
+
+	trailer := &Trailer{Line: r.line}
+
+	count, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+	if err != nil {
+		return &ParseError{Line: r.line, Column: "recordCount", Err: fmt.Errorf("invalid record count %q", fields[1])}
+	}
+	trailer.RecordCount = count
+
+	if trailer.TotalAmount, err = ParseAmount(fields[2]); err != nil {
+		return &ParseError{Line: r.line, Column: "totalAmount", Err: err}
+	}
+	if trailer.TotalFee, err = ParseAmount(fields[3]); err != nil {
+		return &ParseError{Line: r.line, Column: "totalFee", Err: err}
+	}
+	if len(fields) > 4 {
+		trailer.Checksum = strings.ToLower(strings.TrimSpace(fields[4]))
+	}
+
+	switch {
+	case trailer.RecordCount != r.count:
+		return &ParseError{Line: r.line, Column: "recordCount", Err: fmt.Errorf("trailer declares %d records, read %d", trailer.RecordCount, r.count)}
+	case trailer.TotalAmount != r.totalAmount:
+		return &ParseError{Line: r.line, Column: "totalAmount", Err: fmt.Errorf("trailer declares total amount %d, records sum to %d", trailer.TotalAmount, r.totalAmount)}
+	case trailer.TotalFee != r.totalFee:
+		return &ParseError{Line: r.line, Column: "totalFee", Err: fmt.Errorf("trailer declares total fee %d, records sum to %d", trailer.TotalFee, r.totalFee)}
+	}
+
+	if trailer.Checksum != "" {
+		sum := hex.EncodeToString(r.src.checksum.Sum(nil))
+		if sum != trailer.Checksum {
+			return &ParseError{Line: r.line, Column: "checksum", Err: fmt.Errorf("checksum mismatch: trailer declares %s, computed %s", trailer.Checksum, sum)}
+		}
+	}
+
+	// nothing but blank lines may follow the trailer
+	if _, err := r.readRecord(); err != io.EOF {
+		if err == nil {
+			err = &ParseError{Line: r.line, Err: ErrAfterTrailer}
+		}
+		return err
+	}
+
+	r.trailer = trailer
+	return nil
+}
+
+func (r *Reader) parseRecord(fields []string) (*Record, error) {
+	record := &Record{Line: r.line}
+
+	get := func(column string) string {
+		i, ok := r.columns[column]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	if len(fields) != len(r.columns) {
+		return nil, &ParseError{Line: r.line, Err: fmt.Errorf("got %d fields, header has %d", len(fields), len(r.columns))}
+	}
+
+	record.AcquirementID = get(COLUMN_ACQUIREMENT_ID)
+	record.MerchantTransID = get(COLUMN_MERCHANT_TRANS_ID)
+	record.RefundID = get(COLUMN_REFUND_ID)
+	record.TransactionType = strings.ToUpper(get(COLUMN_TRANSACTION_TYPE))
+	record.Currency = get(COLUMN_CURRENCY)
+	record.Status = get(COLUMN_STATUS)
+
+	if record.AcquirementID == "" {
+		return nil, &ParseError{Line: r.line, Column: COLUMN_ACQUIREMENT_ID, Err: errors.New("value is required")}
+	}
+	if record.Currency == "" {
+		record.Currency = dana.CURRENCY_IDR
+	}
+
+	var err error
+	if record.Amount, err = ParseAmount(get(COLUMN_AMOUNT)); err != nil {
+		return nil, &ParseError{Line: r.line, Column: COLUMN_AMOUNT, Err: err}
+	}
+
+	if value := get(COLUMN_FEE); value != "" {
+		if record.Fee, err = ParseAmount(value); err != nil {
+			return nil, &ParseError{Line: r.line, Column: COLUMN_FEE, Err: err}
+		}
+	}
+
+	record.NetAmount = record.Amount - record.Fee
+	if value := get(COLUMN_NET_AMOUNT); value != "" {
+		if record.NetAmount, err = ParseAmount(value); err != nil {
+			return nil, &ParseError{Line: r.line, Column: COLUMN_NET_AMOUNT, Err: err}
+		}
+	}
+
+	if value := get(COLUMN_TRANSACTION_TIME); value != "" {
+		if record.TransactionTime, err = time.Parse(r.TimeLayout, value); err != nil {
+			return nil, &ParseError{Line: r.line, Column: COLUMN_TRANSACTION_TIME, Err: fmt.Errorf("invalid time %q", value)}
+		}
+	}
+
+	return record, nil
+}
+
+// readRecord returns the fields of the next non-blank record, and sets r.line to the line it starts on.
+func (r *Reader) readRecord() ([]string, error) {
+	if r.csv == nil {
+		r.csv = csv.NewReader(r.src)
+		r.csv.Comma = r.Comma
+		r.csv.LazyQuotes = true
+		// the trailer has fewer fields than the records, parseRecord checks the count against the header
+		r.csv.FieldsPerRecord = -1
+	}
+
+	for {
+		fields, err := r.csv.Read()
+		if err == io.EOF {
+			r.line = r.src.lines
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, &ParseError{Line: r.src.lines, Err: err}
+		}
+
+		if len(fields) == 1 && strings.TrimSpace(fields[0]) == "" {
+			continue
+		}
+
+		r.line = r.src.lines - strings.Count(strings.Join(fields, ""), "\n")
+		return fields, nil
+	}
+}
+
+// ParseAmount parses a rupiah amount such as "15000", "15000.5" or "15,000.00" into minor units.
+// A comma is only accepted as a thousands separator.
+func ParseAmount(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	if strings.Contains(s, ",") {
+		if !validThousands(s) {
+			return 0, fmt.Errorf("invalid amount %q: comma is not a thousands separator", value)
+		}
+		s = strings.Replace(s, ",", "", -1)
+	}
+	if s == "" {
+		return 0, errors.New("amount is empty")
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	if len(fraction) > 2 {
+		return 0, fmt.Errorf("invalid amount %q: more than two decimal places", value)
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+	if whole == "" {
+		whole = "0"
+	}
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+// validThousands tells whether every comma of amount separates groups of three digits in its whole part
+func validThousands(amount string) bool {
+	whole := strings.TrimPrefix(amount, "-")
+	if i := strings.IndexByte(whole, '.'); i >= 0 {
+		if strings.Contains(whole[i:], ",") {
+			return false
+		}
+		whole = whole[:i]
+	}
+
+	groups := strings.Split(whole, ",")
+	if len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return false
+	}
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return false
+		}
+	}
+
+	return true
+}
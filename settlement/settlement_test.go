@@ -0,0 +1,136 @@
+package settlement
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const report = `acquirementId|merchantTransId|transactionType|transactionTime|amount|fee|status
+20201001111212800110166|ORDER-1|PAYMENT|2020-10-01T11:12:12+07:00|15000.00|300.00|SUCCESS
+20201001111212800110167|ORDER-2|REFUND|2020-10-01T12:00:00+07:00|-5000|0|SUCCESS
+`
+
+func withTrailer(body string, trailer string) string {
+	sum := md5.Sum([]byte(body))
+	return body + strings.Replace(trailer, "{checksum}", hex.EncodeToString(sum[:]), 1) + "\n"
+}
+
+func newPipeReader(s string) *Reader {
+	r := NewReader(strings.NewReader(s))
+	r.Comma = '|'
+	return r
+}
+
+func TestReadAll(t *testing.T) {
+	r := newPipeReader(withTrailer(report, "TRAILER|2|10000.00|300|{checksum}"))
+
+	records, err := r.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, "20201001111212800110166", records[0].AcquirementID)
+	assert.Equal(t, "ORDER-1", records[0].MerchantTransID)
+	assert.Equal(t, TRANSACTION_TYPE_PAYMENT, records[0].TransactionType)
+	assert.Equal(t, int64(1500000), records[0].Amount)
+	assert.Equal(t, int64(30000), records[0].Fee)
+	assert.Equal(t, int64(1470000), records[0].NetAmount)
+	assert.Equal(t, "IDR", records[0].Currency)
+	assert.Equal(t, 2020, records[0].TransactionTime.Year())
+
+	assert.Equal(t, int64(-500000), records[1].Amount)
+
+	require.NotNil(t, r.Trailer())
+	assert.Equal(t, 2, r.Trailer().RecordCount)
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		line   int
+		column string
+	}{
+		{"missing trailer", report, 4, ""},
+		{"record count", withTrailer(report, "TRAILER|3|10000|300"), 4, "recordCount"},
+		{"total amount", withTrailer(report, "TRAILER|2|10001|300"), 4, "totalAmount"},
+		{"checksum", report + "TRAILER|2|10000|300|00000000000000000000000000000000\n", 4, "checksum"},
+		{"invalid amount", strings.Replace(report, "15000.00", "15k", 1), 2, COLUMN_AMOUNT},
+		{"invalid time", strings.Replace(report, "2020-10-01T12:00:00+07:00", "yesterday", 1), 3, COLUMN_TRANSACTION_TIME},
+		{"missing column", "merchantTransId|amount\n", 1, COLUMN_ACQUIREMENT_ID},
+		{"data after trailer", withTrailer(report, "TRAILER|2|10000|300") + "x|y|z|w|1|0|S\n", 5, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPipeReader(tt.input).ReadAll()
+			require.Error(t, err)
+
+			var perr *ParseError
+			require.True(t, errors.As(err, &perr), "%v", err)
+			assert.Equal(t, tt.line, perr.Line)
+			assert.Equal(t, tt.column, perr.Column)
+		})
+	}
+}
+
+func TestReadStreams(t *testing.T) {
+	r := newPipeReader(withTrailer(report, "TRAILER|2|10000|300"))
+
+	record, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "ORDER-1", record.MerchantTransID)
+	assert.Nil(t, r.Trailer())
+
+	_, err = r.Read()
+	require.NoError(t, err)
+
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+	assert.NotNil(t, r.Trailer())
+}
+
+func TestParseAmount(t *testing.T) {
+	for in, want := range map[string]int64{"15000": 1500000, "15,000.5": 1500050, "1,000,000": 100000000, "0.01": 1, "-20.00": -2000, "-1,500": -150000} {
+		got, err := ParseAmount(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"1.001", "1,5", "1,50", "1,5000", ",500", "15.5,0", "1,000,00"} {
+		_, err := ParseAmount(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestReadQuotedFieldsAndBlankLines(t *testing.T) {
+	body := "acquirementId,merchantTransId,amount,fee,status\n" +
+		"20201001111212800110166,\"ORDER-1\nsecond line\",15000,300,SUCCESS\n" +
+		"\n" +
+		"20201001111212800110167,\"ORDER, 2\",5000,0,SUCCESS\r\n"
+	r := NewReader(strings.NewReader(withTrailer(body, "TRAILER,2,20000,300,{checksum}")))
+
+	records, err := r.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "ORDER-1\nsecond line", records[0].MerchantTransID)
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, "ORDER, 2", records[1].MerchantTransID)
+	assert.Equal(t, 5, records[1].Line)
+	assert.Equal(t, 6, r.Trailer().Line)
+
+	// the checksum covers the raw bytes, a dropped blank line no longer matches
+	sum := md5.Sum([]byte(body))
+	altered := strings.Replace(body, "\n\n", "\n", 1) + "TRAILER,2,20000,300," + hex.EncodeToString(sum[:]) + "\n"
+	_, err = NewReader(strings.NewReader(altered)).ReadAll()
+	var perr *ParseError
+	require.True(t, errors.As(err, &perr), "%v", err)
+	assert.Equal(t, "checksum", perr.Column)
+}
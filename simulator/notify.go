@@ -0,0 +1,180 @@
+// Package simulator plays DANA's side of an integration, to test a merchant's implementation without the sandbox.
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	DEFAULT_VERSION = "2.0"
+
+	RESULT_STATUS_SUCCESS = "S"
+	RESULT_CODE_SUCCESS   = "00000000"
+)
+
+// PayFinishNotifier sends finish payment notifications the way DANA does, and checks the merchant's answers
+type PayFinishNotifier struct {
+	// URL is the merchant's notification endpoint
+	URL      string
+	ClientID string
+	// DanaPrivateKey signs the notifications, the merchant must verify them with the matching public key
+	DanaPrivateKey []byte
+	// MerchantPublicKey verifies the signature of the merchant's answer, nil skips the verification
+	MerchantPublicKey []byte
+	// HTTPClient sends the notifications, nil uses http.DefaultClient
+	HTTPClient *http.Client
+	// Clock returns the time of the notifications, nil uses time.Now
+	Clock func() time.Time
+}
+
+// PayFinishResult is a notification sent and what the merchant answered
+type PayFinishResult struct {
+	Request      dana.PayFinishRequest
+	StatusCode   int
+	ResponseBody []byte
+	Response     dana.PayFinishResponse
+	// Problems lists why the answer is not what DANA expects, empty when it is
+	Problems []string
+}
+
+// NewPayFinishBody : the body of a notification that order moved to status
+func NewPayFinishBody(merchantID, acquirementID, merchantTransID string, amount dana.Amount, status dana.AcquirementStatus) dana.RequestBodyPayFinish {
+	return dana.RequestBodyPayFinish{
+		AcquirementID:     acquirementID,
+		MerchantTransID:   merchantTransID,
+		MerchantID:        merchantID,
+		OrderAmount:       amount,
+		AcquirementStatus: status,
+	}
+}
+
+// Build : the signed notification for body, and the exact bytes to send. Times left empty in body are set to now.
+func (n *PayFinishNotifier) Build(body dana.RequestBodyPayFinish) (req dana.PayFinishRequest, envelope []byte, err error) {
+	now := time.Now()
+	if n.Clock != nil {
+		now = n.Clock()
+	}
+
+	if body.CreatedTime.IsZero() {
+		body.CreatedTime = dana.DanaTime{Time: now}
+	}
+	if body.FinishedTime.IsZero() {
+		body.FinishedTime = dana.DanaTime{Time: now}
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return
+	}
+
+	req.Request = dana.RequestPayFinish{
+		Head: dana.RequestHeader{
+			Version:  DEFAULT_VERSION,
+			Function: dana.FUNCTION_FINISH_NOTIFY,
+			ClientID: n.ClientID,
+			ReqTime:  dana.DanaTime{Time: now}.String(),
+			ReqMsgID: id.String(),
+		},
+		Body: body,
+	}
+
+	signed, err := json.Marshal(req.Request)
+	if err != nil {
+		return
+	}
+
+	req.Signature, err = dana.SignPayload(signed, n.DanaPrivateKey)
+	if err != nil {
+		return
+	}
+
+	envelope = []byte(fmt.Sprintf(`{"request":%s,"signature":%q}`, signed, req.Signature))
+	return
+}
+
+// Send : sign and POST a notification for body, then check the merchant's answer. The error is set when the
+// notification could not be sent or the answer has problems, the result is returned in both cases.
+func (n *PayFinishNotifier) Send(ctx context.Context, body dana.RequestBodyPayFinish) (res PayFinishResult, err error) {
+	req, envelope, err := n.Build(body)
+	if err != nil {
+		return
+	}
+	res.Request = req
+
+	httpReq, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(envelope))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := n.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	httpRes, err := client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer httpRes.Body.Close()
+
+	res.StatusCode = httpRes.StatusCode
+	if res.ResponseBody, err = ioutil.ReadAll(httpRes.Body); err != nil {
+		return
+	}
+
+	res.Problems = n.check(req, res.StatusCode, res.ResponseBody, &res.Response)
+	if len(res.Problems) > 0 {
+		err = fmt.Errorf("invalid notification answer: %s", strings.Join(res.Problems, "; "))
+	}
+
+	return
+}
+
+// check lists what DANA would reject in the merchant's answer to req
+func (n *PayFinishNotifier) check(req dana.PayFinishRequest, statusCode int, body []byte, res *dana.PayFinishResponse) (problems []string) {
+	if statusCode != http.StatusOK {
+		problems = append(problems, fmt.Sprintf("HTTP status is %d, not 200", statusCode))
+	}
+
+	if err := json.Unmarshal(body, res); err != nil {
+		return append(problems, fmt.Sprintf("body is not a PayFinishResponse: %v", err))
+	}
+
+	head := res.Response.Head
+	if head.Function != dana.FUNCTION_FINISH_NOTIFY {
+		problems = append(problems, fmt.Sprintf("response head function is %q, not %q", head.Function, dana.FUNCTION_FINISH_NOTIFY))
+	}
+	if head.ClientID == "" || head.Version == "" || head.RespTime == "" {
+		problems = append(problems, "response head must have clientId, version and respTime")
+	}
+	if head.RespMsgID != req.Request.Head.ReqMsgID {
+		problems = append(problems, fmt.Sprintf("response head reqMsgId is %q, not the notification's %q", head.RespMsgID, req.Request.Head.ReqMsgID))
+	}
+
+	result := res.Response.Body.ResultInfo
+	if result.ResultStatus != RESULT_STATUS_SUCCESS || result.ResultCodeID != RESULT_CODE_SUCCESS {
+		problems = append(problems, fmt.Sprintf("resultInfo is %s %s %q, DANA retries the notification until it is S %s",
+			result.ResultStatus, result.ResultCodeID, result.ResultMsg, RESULT_CODE_SUCCESS))
+	}
+
+	if n.MerchantPublicKey != nil {
+		signed := gjson.GetBytes(body, "response").Raw
+		if err := dana.VerifyPayload([]byte(signed), res.Signature, n.MerchantPublicKey); err != nil {
+			problems = append(problems, fmt.Sprintf("response signature does not verify with the merchant public key: %v", err))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,139 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type keyPair struct {
+	private []byte
+	public  []byte
+}
+
+func newKeyPair(t *testing.T) keyPair {
+	private, public, err := dana.GenerateKeyPair(dana.DEFAULT_KEY_BITS)
+	require.NoError(t, err)
+	return keyPair{private: private, public: public}
+}
+
+// newMerchant answers notifications like a merchant using the library, answer may change the response before it is signed.
+// A notification the merchant cannot handle is answered with an error status and reported on errs.
+func newMerchant(t *testing.T, danaKey, merchantKey keyPair, answer func(res *dana.ResponsePayFinish)) (*httptest.Server, chan dana.RequestBodyPayFinish, chan error) {
+	received := make(chan dana.RequestBodyPayFinish, 1)
+	errs := make(chan error, 10)
+	gateway := dana.CoreGateway{Client: dana.Client{PrivateKey: merchantKey.private, PublicKey: danaKey.public}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		var req dana.PayFinishRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			errs <- fmt.Errorf("invalid notification: %v", err)
+			return
+		}
+		if err := gateway.VerifySignature(body, req.Signature); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- req.Request.Body
+
+		res := dana.ResponsePayFinish{
+			Head: dana.ResponseHeader{
+				Function:  req.Request.Head.Function,
+				ClientID:  req.Request.Head.ClientID,
+				Version:   req.Request.Head.Version,
+				RespTime:  dana.DanaTime{Time: time.Now()}.String(),
+				RespMsgID: req.Request.Head.ReqMsgID,
+			},
+			Body: dana.ResponseBodyPayFinish{ResultInfo: dana.ResultInfo{ResultStatus: "S", ResultCodeID: "00000000", ResultCode: "SUCCESS"}},
+		}
+		if answer != nil {
+			answer(&res)
+		}
+
+		sig, err := gateway.GenerateSignature(res)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			errs <- err
+			return
+		}
+		json.NewEncoder(w).Encode(dana.PayFinishResponse{Response: res, Signature: sig})
+	}))
+
+	return server, received, errs
+}
+
+// assertNoMerchantErrors fails t with the errors the merchant reported so far
+func assertNoMerchantErrors(t *testing.T, errs chan error) {
+	for {
+		select {
+		case err := <-errs:
+			assert.NoError(t, err, "merchant")
+		default:
+			return
+		}
+	}
+}
+
+func TestPayFinishNotifier(t *testing.T) {
+	danaKey, merchantKey := newKeyPair(t), newKeyPair(t)
+	server, received, errs := newMerchant(t, danaKey, merchantKey, nil)
+	defer server.Close()
+
+	notifier := &PayFinishNotifier{
+		URL:               server.URL,
+		ClientID:          "client-id",
+		DanaPrivateKey:    danaKey.private,
+		MerchantPublicKey: merchantKey.public,
+		Clock:             func() time.Time { return time.Date(2020, 10, 1, 4, 12, 12, 0, time.UTC) },
+	}
+
+	body := NewPayFinishBody("m", "acq-1", "ORDER-1", dana.Amount{Currency: dana.CURRENCY_IDR, Value: "1500000"}, dana.ACQUIREMENT_STATUS_SUCCESS)
+	res, err := notifier.Send(context.Background(), body)
+	require.NoError(t, err)
+	assert.Empty(t, res.Problems)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "S", res.Response.Response.Body.ResultInfo.ResultStatus)
+
+	notification := <-received
+	assert.Equal(t, "ORDER-1", notification.MerchantTransID)
+	assert.Equal(t, dana.ACQUIREMENT_STATUS_SUCCESS, notification.AcquirementStatus)
+	assert.Equal(t, "2020-10-01T11:12:12+07:00", notification.FinishedTime.String())
+	assertNoMerchantErrors(t, errs)
+}
+
+func TestPayFinishNotifierReportsProblems(t *testing.T) {
+	danaKey, merchantKey, otherKey := newKeyPair(t), newKeyPair(t), newKeyPair(t)
+	server, _, errs := newMerchant(t, danaKey, merchantKey, func(res *dana.ResponsePayFinish) {
+		res.Head.RespMsgID = "other"
+		res.Body.ResultInfo = dana.ResultInfo{ResultStatus: "F", ResultCodeID: "00000900", ResultMsg: "SYSTEM_ERROR"}
+	})
+	defer server.Close()
+
+	notifier := &PayFinishNotifier{URL: server.URL, ClientID: "client-id", DanaPrivateKey: danaKey.private, MerchantPublicKey: otherKey.public}
+	res, err := notifier.Send(context.Background(), NewPayFinishBody("m", "acq-1", "ORDER-1", dana.Amount{}, dana.ACQUIREMENT_STATUS_CLOSED))
+	require.Error(t, err)
+	require.Len(t, res.Problems, 3)
+	assert.Contains(t, res.Problems[0], `reqMsgId is "other"`)
+	assert.Contains(t, res.Problems[1], "F 00000900")
+	assert.Contains(t, res.Problems[2], "does not verify")
+
+	// a merchant rejecting DANA's signature
+	notifier.DanaPrivateKey = otherKey.private
+	res, err = notifier.Send(context.Background(), NewPayFinishBody("m", "acq-1", "ORDER-1", dana.Amount{}, dana.ACQUIREMENT_STATUS_CLOSED))
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	assert.Contains(t, res.Problems[0], "HTTP status is 401")
+	assertNoMerchantErrors(t, errs)
+}
@@ -0,0 +1,655 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	CHECKOUT_PATH = "/checkout/"
+
+	CHECKOUT_ACTION_PAY    = "pay"
+	CHECKOUT_ACTION_CANCEL = "cancel"
+
+	RESULT_STATUS_FAILURE = "F"
+
+	// failures are told apart by ResultCode, their ResultCodeID is left empty
+	RESULT_CODE_PARAM_ILLEGAL           = "PARAM_ILLEGAL"
+	RESULT_CODE_INVALID_SIGNATURE       = "INVALID_SIGNATURE"
+	RESULT_CODE_ORDER_NOT_EXIST         = "ORDER_NOT_EXIST"
+	RESULT_CODE_ORDER_STATUS_INVALID    = "ORDER_STATUS_INVALID"
+	RESULT_CODE_REPEAT_REQ_INCONSISTENT = "REPEAT_REQ_INCONSISTENT"
+	RESULT_CODE_REFUND_AMOUNT_EXCEED    = "REFUND_AMOUNT_EXCEED"
+	RESULT_CODE_REFUND_COUNT_EXCEED     = "REFUND_COUNT_EXCEED"
+)
+
+// ErrOrderNotFound is returned for an acquirement id the simulator did not create
+var ErrOrderNotFound = errors.New("order not found")
+
+// Server is an in-process DANA keeping orders in memory. It answers createOrder, query and refund
+// consistently, serves the checkoutUrl of every order where a test can pay or cancel it, closes
+// orders past their expiry time, and sends signed finish payment notifications to the
+// NOTIFICATION urls of an order once it reaches a final status.
+type Server struct {
+	// URL is the base URL to use as the client BaseUrl
+	URL string
+	// DanaPrivateKey signs responses and notifications, the client PublicKey must be its public key
+	DanaPrivateKey []byte
+	// MerchantPublicKey verifies the signature of requests and of notification answers, nil skips the verification
+	MerchantPublicKey []byte
+	// MaxRefunds is the number of refunds an order accepts, 0 means no limit besides the paid amount
+	MaxRefunds int
+	// HTTPClient sends the notifications, nil uses http.DefaultClient
+	HTTPClient *http.Client
+	// Clock returns the current time, nil uses time.Now. Moving it past an order expiry time closes the order.
+	Clock func() time.Time
+
+	server *httptest.Server
+
+	mu                sync.Mutex
+	sequence          int
+	orders            map[string]*order
+	byMerchantTransID map[string]string
+	pending           []*order
+	notifications     []Notification
+
+	// queued notifications are sent in order by one goroutine at a time, sent is signalled when the queue is empty
+	queue   []notificationSend
+	sending bool
+	sent    *sync.Cond
+}
+
+type notificationSend struct {
+	url  string
+	body dana.RequestBodyPayFinish
+	o    *order
+}
+
+// Notification is a finish payment notification sent by the Server
+type Notification struct {
+	URL    string
+	Result PayFinishResult
+	// Err is set when the notification could not be sent or the answer has problems
+	Err error
+}
+
+type order struct {
+	state         *dana.OrderState
+	merchantID    string
+	clientID      string
+	request       dana.OrderRequestData
+	amount        int64
+	createdTime   time.Time
+	expiryTime    time.Time
+	paidTime      time.Time
+	cancelledTime time.Time
+	refunded      int64
+	refunds       map[string]refund
+}
+
+// resultBody is the body of a request rejected before its function is run
+type resultBody struct {
+	ResultInfo dana.ResultInfo `json:"resultInfo"`
+}
+
+type refund struct {
+	id     string
+	amount int64
+}
+
+// handler answers the body of a signed request, clientID is the clientId of its head
+type handler struct {
+	function string
+	serve    func(s *Server, clientID string, body []byte) interface{}
+}
+
+var handlers = map[string]handler{
+	"/" + dana.ORDER_PATH:  {function: dana.FUNCTION_CREATE_ORDER, serve: (*Server).createOrder},
+	"/" + dana.QUERY_PATH:  {function: dana.FUNCTION_QUERY_ORDER, serve: (*Server).queryOrder},
+	"/" + dana.REFUND_PATH: {function: dana.FUNCTION_REFUND, serve: (*Server).refund},
+}
+
+// NewServer : start a simulator on a local port, Close stops it
+func NewServer(danaPrivateKey, merchantPublicKey []byte) *Server {
+	s := &Server{
+		DanaPrivateKey:    danaPrivateKey,
+		MerchantPublicKey: merchantPublicKey,
+		orders:            map[string]*order{},
+		byMerchantTransID: map[string]string{},
+	}
+	s.sent = sync.NewCond(&s.mu)
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close : stop the simulator once the queued notifications are sent
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.waitSent()
+	s.mu.Unlock()
+
+	s.server.Close()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, CHECKOUT_PATH) {
+		s.serveCheckout(w, r)
+		return
+	}
+
+	h, ok := handlers[r.URL.Path]
+	if !ok || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := gjson.GetBytes(data, "request")
+	head := dana.RequestHeader{}
+	if err = json.Unmarshal([]byte(request.Get("head").Raw), &head); err != nil {
+		http.Error(w, "invalid request envelope", http.StatusBadRequest)
+		return
+	}
+
+	var body interface{}
+	switch {
+	case s.MerchantPublicKey != nil && dana.VerifyPayload([]byte(request.Raw), gjson.GetBytes(data, "signature").String(), s.MerchantPublicKey) != nil:
+		body = resultBody{ResultInfo: failure(RESULT_CODE_INVALID_SIGNATURE, "request signature does not verify with the merchant public key")}
+	case head.Function != h.function:
+		body = resultBody{ResultInfo: failure(RESULT_CODE_PARAM_ILLEGAL, fmt.Sprintf("function must be %s", h.function))}
+	default:
+		body = h.serve(s, head.ClientID, []byte(request.Get("body").Raw))
+	}
+
+	s.reply(w, head, body)
+	s.flush()
+}
+
+// reply writes body in a response envelope signed with DanaPrivateKey
+func (s *Server) reply(w http.ResponseWriter, head dana.RequestHeader, body interface{}) {
+	res := dana.Response{
+		Head: dana.ResponseHeader{
+			Function:  head.Function,
+			ClientID:  head.ClientID,
+			Version:   head.Version,
+			RespTime:  dana.DanaTime{Time: s.now()}.String(),
+			RespMsgID: head.ReqMsgID,
+		},
+		Body: body,
+	}
+
+	signed, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sig, err := dana.SignPayload(signed, s.DanaPrivateKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"response":%s,"signature":%q}`, signed, sig)
+}
+
+func (s *Server) createOrder(clientID string, body []byte) interface{} {
+	var req dana.OrderRequestData
+	if err := json.Unmarshal(body, &req); err != nil {
+		return orderFailure(RESULT_CODE_PARAM_ILLEGAL, err.Error())
+	}
+
+	amount, err := parseAmount(req.Order.OrderAmount)
+	if err != nil || amount <= 0 {
+		return orderFailure(RESULT_CODE_PARAM_ILLEGAL, fmt.Sprintf("invalid orderAmount %q", req.Order.OrderAmount.Value))
+	}
+	if req.MerchantID == "" || req.Order.MerchantTransID == "" {
+		return orderFailure(RESULT_CODE_PARAM_ILLEGAL, "merchantId and merchantTransId are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a repeated request gets the order created the first time
+	if id, ok := s.byMerchantTransID[merchantTransKey(req.MerchantID, req.Order.MerchantTransID)]; ok {
+		o := s.orders[id]
+		if o.amount != amount {
+			return orderFailure(RESULT_CODE_REPEAT_REQ_INCONSISTENT, "merchantTransId was used for a different amount")
+		}
+
+		return dana.OrderResponseData{
+			MerchantTransID: o.state.MerchantTransID,
+			AcquirementID:   o.state.AcquirementID,
+			CheckoutURL:     s.checkoutURL(o),
+			ResultInfo:      success(),
+		}
+	}
+
+	now := s.now()
+	o := &order{
+		state:       dana.NewOrderState(s.newID(now), req.Order.MerchantTransID),
+		merchantID:  req.MerchantID,
+		clientID:    clientID,
+		request:     req,
+		amount:      amount,
+		createdTime: now,
+		expiryTime:  now.Add(dana.DEFAULT_ORDER_EXPIRY),
+		refunds:     map[string]refund{},
+	}
+	if req.Order.CreatedTime != nil && !req.Order.CreatedTime.IsZero() {
+		o.createdTime = req.Order.CreatedTime.Time
+	}
+	if req.Order.ExpiryTime != nil && !req.Order.ExpiryTime.IsZero() {
+		o.expiryTime = req.Order.ExpiryTime.Time
+	}
+
+	s.orders[o.state.AcquirementID] = o
+	s.byMerchantTransID[merchantTransKey(o.merchantID, o.state.MerchantTransID)] = o.state.AcquirementID
+
+	return dana.OrderResponseData{
+		MerchantTransID: o.state.MerchantTransID,
+		AcquirementID:   o.state.AcquirementID,
+		CheckoutURL:     s.checkoutURL(o),
+		ResultInfo:      success(),
+	}
+}
+
+func (s *Server) queryOrder(clientID string, body []byte) interface{} {
+	var req dana.OrderDetailRequestData
+	if err := json.Unmarshal(body, &req); err != nil {
+		return dana.OrderDetailData{ResultInfo: failure(RESULT_CODE_PARAM_ILLEGAL, err.Error())}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := req.AcquirementID
+	if id == "" {
+		id = s.byMerchantTransID[merchantTransKey(req.MerchantID, req.MerchantTransID)]
+	}
+
+	o, ok := s.orders[id]
+	if !ok || o.merchantID != req.MerchantID {
+		return dana.OrderDetailData{ResultInfo: failure(RESULT_CODE_ORDER_NOT_EXIST, "order does not exist")}
+	}
+
+	s.expireDue(o)
+	return s.detail(o)
+}
+
+func (s *Server) refund(clientID string, body []byte) interface{} {
+	var req dana.RefundRequestData
+	if err := json.Unmarshal(body, &req); err != nil {
+		return refundFailure(req, RESULT_CODE_PARAM_ILLEGAL, err.Error())
+	}
+
+	amount, err := parseAmount(req.RefundAmount)
+	if err != nil || amount <= 0 || req.RequestID == "" {
+		return refundFailure(req, RESULT_CODE_PARAM_ILLEGAL, "requestId and a positive refundAmount are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[req.AcquirementID]
+	if !ok || o.merchantID != req.MerchantID {
+		return refundFailure(req, RESULT_CODE_ORDER_NOT_EXIST, "order does not exist")
+	}
+
+	// a repeated request gets the refund made the first time
+	if previous, ok := o.refunds[req.RequestID]; ok {
+		if previous.amount != amount {
+			return refundFailure(req, RESULT_CODE_REPEAT_REQ_INCONSISTENT, "requestId was used for a different amount")
+		}
+
+		return dana.RefundResponseData{ResultInfo: success(), RequestID: req.RequestID, RefundID: previous.id}
+	}
+
+	s.expireDue(o)
+	switch {
+	case o.state.Status != dana.ACQUIREMENT_STATUS_SUCCESS:
+		return refundFailure(req, RESULT_CODE_ORDER_STATUS_INVALID, fmt.Sprintf("order is %s, only paid orders can be refunded", o.state.Status))
+	case o.refunded+amount > o.amount:
+		return refundFailure(req, RESULT_CODE_REFUND_AMOUNT_EXCEED, fmt.Sprintf("%d is already refunded of %d", o.refunded, o.amount))
+	case s.MaxRefunds > 0 && len(o.refunds) >= s.MaxRefunds:
+		return refundFailure(req, RESULT_CODE_REFUND_COUNT_EXCEED, fmt.Sprintf("order accepts %d refunds", s.MaxRefunds))
+	}
+
+	r := refund{id: s.newID(s.now()), amount: amount}
+	o.refunds[req.RequestID] = r
+	o.refunded += amount
+
+	return dana.RefundResponseData{ResultInfo: success(), RequestID: req.RequestID, RefundID: r.id}
+}
+
+// detail : the order as query returns it
+func (s *Server) detail(o *order) dana.OrderDetailData {
+	detail := dana.OrderDetailData{
+		ResultInfo:      success(),
+		AcquirementID:   o.state.AcquirementID,
+		MerchantTransID: o.state.MerchantTransID,
+		OrderTitle:      o.request.Order.OrderTitle,
+		OrderMemo:       o.request.Order.OrderMemo,
+		ExtendedInfo:    o.request.ExtendInfo,
+		Goods:           o.request.Order.Goods,
+		ShippingInfo:    o.request.Order.ShippingInfo,
+		AmountDetail: dana.AmountDetail{
+			OrderAmount:  amount(o.amount),
+			RefundAmount: amount(o.refunded),
+		},
+		TimeDetail: dana.TimeDetail{
+			CreatedTime: dana.DanaTime{Time: o.createdTime},
+			ExpiryTime:  dana.DanaTime{Time: o.expiryTime},
+		},
+		StatusDetail: dana.StatusDetail{AcquirementStatus: o.state.Status},
+	}
+
+	if o.state.Status.IsPaid() {
+		detail.AmountDetail.PayAmount = amount(o.amount)
+		detail.TimeDetail.PaidTimes = []dana.DanaTime{{Time: o.paidTime}}
+	}
+	if !o.cancelledTime.IsZero() {
+		detail.TimeDetail.CancelledTime = dana.DanaTime{Time: o.cancelledTime}
+	}
+
+	return detail
+}
+
+// Order : the order as query returns it, ErrOrderNotFound for an unknown acquirementID
+func (s *Server) Order(acquirementID string) (res dana.OrderDetailData, err error) {
+	s.mu.Lock()
+	o, ok := s.orders[acquirementID]
+	if ok {
+		s.expireDue(o)
+		res = s.detail(o)
+	}
+	s.mu.Unlock()
+	s.flush()
+
+	if !ok {
+		err = ErrOrderNotFound
+	}
+	return
+}
+
+// Pay : pay the order as a user would on the checkout page
+func (s *Server) Pay(acquirementID string) error {
+	return s.transition(acquirementID, dana.ACQUIREMENT_STATUS_SUCCESS)
+}
+
+// Cancel : cancel the order as a user would on the checkout page
+func (s *Server) Cancel(acquirementID string) error {
+	return s.transition(acquirementID, dana.ACQUIREMENT_STATUS_CANCELLED)
+}
+
+// Expire : close the order now as if its expiry time had passed
+func (s *Server) Expire(acquirementID string) error {
+	return s.transition(acquirementID, dana.ACQUIREMENT_STATUS_CLOSED)
+}
+
+// Notifications : the notifications sent so far, in order. It waits for the queued notifications to be sent.
+func (s *Server) Notifications() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.waitSent()
+	return append([]Notification(nil), s.notifications...)
+}
+
+func (s *Server) transition(acquirementID string, next dana.AcquirementStatus) (err error) {
+	s.mu.Lock()
+	o, ok := s.orders[acquirementID]
+	if ok {
+		s.expireDue(o)
+		err = s.apply(o, next)
+	}
+	s.mu.Unlock()
+	s.flush()
+
+	if !ok {
+		err = ErrOrderNotFound
+	}
+	return
+}
+
+// apply moves o to next and queues its notification, s.mu must be held
+func (s *Server) apply(o *order, next dana.AcquirementStatus) error {
+	changed, err := o.state.Apply(next)
+	if err != nil || !changed {
+		return err
+	}
+
+	now := s.now()
+	switch next {
+	case dana.ACQUIREMENT_STATUS_SUCCESS:
+		o.paidTime = now
+	case dana.ACQUIREMENT_STATUS_CANCELLED:
+		o.cancelledTime = now
+	case dana.ACQUIREMENT_STATUS_CLOSED:
+		if now.Before(o.expiryTime) {
+			o.expiryTime = now
+		}
+	}
+
+	if next.IsFinal() {
+		s.pending = append(s.pending, o)
+	}
+	return nil
+}
+
+// expireDue closes o when its expiry time has passed, s.mu must be held
+func (s *Server) expireDue(o *order) {
+	if !o.state.Status.IsFinal() && !s.now().Before(o.expiryTime) {
+		_ = s.apply(o, dana.ACQUIREMENT_STATUS_CLOSED)
+	}
+}
+
+// flush queues the notifications of the orders that reached a final status. They are sent in the background,
+// so the request or call that triggered them returns first, as with DANA.
+func (s *Server) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, o := range s.pending {
+		body := NewPayFinishBody(o.merchantID, o.state.AcquirementID, o.state.MerchantTransID, amount(o.amount), o.state.Status)
+		body.CreatedTime = dana.DanaTime{Time: o.createdTime}
+		for _, u := range notificationURLs(o, dana.NOTIFICATION_URL_TYPE_NOTIFICATION) {
+			s.queue = append(s.queue, notificationSend{url: u, body: body, o: o})
+		}
+	}
+	s.pending = nil
+
+	if len(s.queue) > 0 && !s.sending {
+		s.sending = true
+		go s.send()
+	}
+}
+
+// send sends the queued notifications until the queue is empty
+func (s *Server) send() {
+	s.mu.Lock()
+	for len(s.queue) > 0 {
+		n := s.queue[0]
+		s.queue = s.queue[1:]
+		notifier := &PayFinishNotifier{
+			URL:               n.url,
+			ClientID:          n.o.clientID,
+			DanaPrivateKey:    s.DanaPrivateKey,
+			MerchantPublicKey: s.MerchantPublicKey,
+			HTTPClient:        s.HTTPClient,
+			Clock:             s.Clock,
+		}
+		s.mu.Unlock()
+
+		res, err := notifier.Send(context.Background(), n.body)
+
+		s.mu.Lock()
+		s.notifications = append(s.notifications, Notification{URL: n.url, Result: res, Err: err})
+	}
+
+	s.sending = false
+	s.sent.Broadcast()
+	s.mu.Unlock()
+}
+
+// waitSent waits until the queued notifications are sent, s.mu must be held
+func (s *Server) waitSent() {
+	for s.sending {
+		s.sent.Wait()
+	}
+}
+
+var checkoutPage = template.Must(template.New("checkout").Parse(`<!DOCTYPE html>
+<html>
+<head><title>DANA simulator checkout</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Order {{.MerchantTransID}}, acquirement {{.AcquirementID}}</p>
+<p>Amount: {{.Amount}}</p>
+<p>Status: <span id="status">{{.Status}}</span></p>
+{{if not .Final}}
+<form method="post" action="{{.Path}}/pay"><button id="pay" type="submit">Pay</button></form>
+<form method="post" action="{{.Path}}/cancel"><button id="cancel" type="submit">Cancel</button></form>
+{{end}}
+</body>
+</html>
+`))
+
+// serveCheckout serves the checkoutUrl page, and its pay and cancel actions. An action redirects
+// to the PAY_RETURN url of the order, or back to the page when there is none.
+func (s *Server) serveCheckout(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, CHECKOUT_PATH), "/")
+	id := parts[0]
+
+	s.mu.Lock()
+	o, ok := s.orders[id]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 1 && r.Method == http.MethodGet {
+		detail, _ := s.Order(id)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		checkoutPage.Execute(w, map[string]interface{}{
+			"Title":           detail.OrderTitle,
+			"MerchantTransID": detail.MerchantTransID,
+			"AcquirementID":   detail.AcquirementID,
+			"Amount":          detail.AmountDetail.OrderAmount.Currency + " " + detail.AmountDetail.OrderAmount.Value,
+			"Status":          detail.StatusDetail.AcquirementStatus,
+			"Final":           detail.StatusDetail.AcquirementStatus.IsFinal(),
+			"Path":            CHECKOUT_PATH + id,
+		})
+		return
+	}
+
+	if len(parts) != 2 || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	var err error
+	switch parts[1] {
+	case CHECKOUT_ACTION_PAY:
+		err = s.Pay(id)
+	case CHECKOUT_ACTION_CANCEL:
+		err = s.Cancel(id)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	returnURL := CHECKOUT_PATH + id
+	s.mu.Lock()
+	if urls := notificationURLs(o, dana.NOTIFICATION_URL_TYPE_PAY_RETURN); len(urls) > 0 {
+		returnURL = urls[0]
+	}
+	s.mu.Unlock()
+
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+func (s *Server) checkoutURL(o *order) string {
+	return s.URL + CHECKOUT_PATH + o.state.AcquirementID
+}
+
+func (s *Server) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock()
+	}
+	return time.Now()
+}
+
+// newID : a unique id shaped like DANA's, s.mu must be held
+func (s *Server) newID(now time.Time) string {
+	s.sequence++
+	return fmt.Sprintf("%s%010d", now.In(dana.DanaTimeLocation).Format("20060102150405"), s.sequence)
+}
+
+func notificationURLs(o *order, urlType string) (urls []string) {
+	if o.request.NotificationUrls == nil {
+		return
+	}
+
+	for _, u := range *o.request.NotificationUrls {
+		if u.Type == urlType {
+			urls = append(urls, u.URL)
+		}
+	}
+	return
+}
+
+func merchantTransKey(merchantID, merchantTransID string) string {
+	return merchantID + "/" + merchantTransID
+}
+
+// parseAmount : the value of a in minor units
+func parseAmount(a dana.Amount) (int64, error) {
+	return strconv.ParseInt(a.Value, 10, 64)
+}
+
+func amount(value int64) dana.Amount {
+	return dana.Amount{Currency: dana.CURRENCY_IDR, Value: strconv.FormatInt(value, 10)}
+}
+
+func success() dana.ResultInfo {
+	return dana.ResultInfo{ResultStatus: RESULT_STATUS_SUCCESS, ResultCodeID: RESULT_CODE_SUCCESS, ResultCode: "SUCCESS", ResultMsg: "success"}
+}
+
+func failure(code, msg string) dana.ResultInfo {
+	return dana.ResultInfo{ResultStatus: RESULT_STATUS_FAILURE, ResultCode: code, ResultMsg: msg}
+}
+
+func orderFailure(code, msg string) dana.OrderResponseData {
+	return dana.OrderResponseData{ResultInfo: failure(code, msg)}
+}
+
+func refundFailure(req dana.RefundRequestData, code, msg string) dana.RefundResponseData {
+	return dana.RefundResponseData{ResultInfo: failure(code, msg), RequestID: req.RequestID}
+}
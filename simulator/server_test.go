@@ -0,0 +1,212 @@
+package simulator
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	dana "github.com/kitabisa/sangu-dana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMerchantID = "216620000000000000000"
+
+func newTestGateway(sim *Server, merchantKey, danaKey keyPair) dana.CoreGateway {
+	client := dana.NewClient()
+	client.LogLevel = 0
+	client.BaseUrl = sim.URL
+	client.Version = DEFAULT_VERSION
+	client.ClientId = "client-id"
+	client.ClientSecret = "client-secret"
+	client.PrivateKey = merchantKey.private
+	client.PublicKey = danaKey.public
+
+	return dana.CoreGateway{Client: client}
+}
+
+func TestServerPaymentFlow(t *testing.T) {
+	danaKey, merchantKey := newKeyPair(t), newKeyPair(t)
+	merchant, received, errs := newMerchant(t, danaKey, merchantKey, nil)
+	defer merchant.Close()
+
+	sim := NewServer(danaKey.private, merchantKey.public)
+	defer sim.Close()
+	sim.MaxRefunds = 2
+	gateway := newTestGateway(sim, merchantKey, danaKey)
+
+	req, err := dana.NewOrderBuilder(testMerchantID, "ORDER-1").
+		Title("Donation").
+		ProductCode("51051000100000000001").
+		AddGood(dana.Good{Description: "Book"}, 15000, 1).
+		PayReturnURL("https://example.com/return").
+		NotificationURL(merchant.URL).
+		Build()
+	require.NoError(t, err)
+
+	order, err := gateway.Order(req, "")
+	require.NoError(t, err)
+	require.Equal(t, RESULT_STATUS_SUCCESS, order.Response.Body.ResultInfo.ResultStatus)
+	acquirementID := order.Response.Body.AcquirementID
+	assert.Equal(t, sim.URL+CHECKOUT_PATH+acquirementID, order.Response.Body.CheckoutURL)
+
+	page, err := http.Get(order.Response.Body.CheckoutURL)
+	require.NoError(t, err)
+	html, _ := ioutil.ReadAll(page.Body)
+	page.Body.Close()
+	assert.Contains(t, string(html), `<span id="status">INIT</span>`)
+
+	// the user pays on the checkout page and is sent back to the merchant
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	paid, err := browser.Post(order.Response.Body.CheckoutURL+"/"+CHECKOUT_ACTION_PAY, "", nil)
+	require.NoError(t, err)
+	paid.Body.Close()
+	assert.Equal(t, http.StatusSeeOther, paid.StatusCode)
+	assert.Equal(t, "https://example.com/return", paid.Header.Get("Location"))
+
+	notification := <-received
+	assert.Equal(t, acquirementID, notification.AcquirementID)
+	assert.Equal(t, dana.ACQUIREMENT_STATUS_SUCCESS, notification.AcquirementStatus)
+	assert.Equal(t, "1500000", notification.OrderAmount.Value)
+	notifications := sim.Notifications()
+	require.Len(t, notifications, 1)
+	assert.NoError(t, notifications[0].Err)
+
+	detail, err := gateway.OrderDetail(&dana.OrderDetailRequestData{MerchantID: testMerchantID, MerchantTransID: "ORDER-1"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, dana.ACQUIREMENT_STATUS_SUCCESS, detail.Response.Body.StatusDetail.AcquirementStatus)
+	assert.Equal(t, "1500000", detail.Response.Body.AmountDetail.PayAmount.Value)
+	assert.Len(t, detail.Response.Body.TimeDetail.PaidTimes, 1)
+
+	refund := func(requestID, value string) dana.RefundResponseData {
+		res, err := gateway.Refund(&dana.RefundRequestData{
+			RequestID:     requestID,
+			MerchantID:    testMerchantID,
+			AcquirementID: acquirementID,
+			RefundAmount:  dana.Amount{Currency: dana.CURRENCY_IDR, Value: value},
+		}, "")
+		require.NoError(t, err)
+		return res.Response.Body
+	}
+
+	first := refund("refund-1", "5000")
+	assert.Equal(t, RESULT_STATUS_SUCCESS, first.ResultInfo.ResultStatus)
+	assert.Equal(t, first.RefundID, refund("refund-1", "5000").RefundID, "a repeated request is the same refund")
+	assert.Equal(t, RESULT_CODE_REPEAT_REQ_INCONSISTENT, refund("refund-1", "6000").ResultInfo.ResultCode)
+	assert.Equal(t, RESULT_CODE_REFUND_AMOUNT_EXCEED, refund("refund-2", "10001").ResultInfo.ResultCode)
+	assert.Equal(t, RESULT_STATUS_SUCCESS, refund("refund-2", "5000").ResultInfo.ResultStatus)
+	assert.Equal(t, RESULT_CODE_REFUND_COUNT_EXCEED, refund("refund-3", "1").ResultInfo.ResultCode)
+
+	state, err := sim.Order(acquirementID)
+	require.NoError(t, err)
+	assert.Equal(t, "1000000", state.AmountDetail.RefundAmount.Value)
+
+	// a paid order can no longer be cancelled
+	assert.True(t, errors.Is(sim.Cancel(acquirementID), dana.ErrIllegalStatusTransition))
+	assertNoMerchantErrors(t, errs)
+}
+
+func TestServerExpiresOrders(t *testing.T) {
+	danaKey, merchantKey := newKeyPair(t), newKeyPair(t)
+	merchant, received, errs := newMerchant(t, danaKey, merchantKey, nil)
+	defer merchant.Close()
+
+	now := time.Date(2020, 10, 1, 4, 0, 0, 0, time.UTC)
+	sim := NewServer(danaKey.private, merchantKey.public)
+	defer sim.Close()
+	sim.Clock = func() time.Time { return now }
+	gateway := newTestGateway(sim, merchantKey, danaKey)
+
+	req, err := dana.NewOrderBuilder(testMerchantID, "ORDER-2").
+		Title("Donation").
+		ProductCode("51051000100000000001").
+		Amount(20000).
+		CreatedAt(now).
+		ExpiresIn(time.Hour).
+		NotificationURL(merchant.URL).
+		Build()
+	require.NoError(t, err)
+
+	order, err := gateway.Order(req, "")
+	require.NoError(t, err)
+	acquirementID := order.Response.Body.AcquirementID
+
+	now = now.Add(time.Hour)
+
+	detail, err := gateway.OrderDetail(&dana.OrderDetailRequestData{MerchantID: testMerchantID, AcquirementID: acquirementID}, "")
+	require.NoError(t, err)
+	assert.Equal(t, dana.ACQUIREMENT_STATUS_CLOSED, detail.Response.Body.StatusDetail.AcquirementStatus)
+	assert.Equal(t, dana.ACQUIREMENT_STATUS_CLOSED, (<-received).AcquirementStatus)
+
+	assert.True(t, errors.Is(sim.Pay(acquirementID), dana.ErrIllegalStatusTransition))
+
+	res, err := gateway.Refund(&dana.RefundRequestData{
+		RequestID:     "refund-1",
+		MerchantID:    testMerchantID,
+		AcquirementID: acquirementID,
+		RefundAmount:  dana.Amount{Currency: dana.CURRENCY_IDR, Value: "1000"},
+	}, "")
+	require.NoError(t, err)
+	assert.Equal(t, RESULT_CODE_ORDER_STATUS_INVALID, res.Response.Body.ResultInfo.ResultCode)
+	assertNoMerchantErrors(t, errs)
+}
+
+func TestServerRejectsUnknownOrdersAndSignatures(t *testing.T) {
+	danaKey, merchantKey := newKeyPair(t), newKeyPair(t)
+	sim := NewServer(danaKey.private, merchantKey.public)
+	defer sim.Close()
+
+	gateway := newTestGateway(sim, merchantKey, danaKey)
+	detail, err := gateway.OrderDetail(&dana.OrderDetailRequestData{MerchantID: testMerchantID, AcquirementID: "unknown"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, RESULT_CODE_ORDER_NOT_EXIST, detail.Response.Body.ResultInfo.ResultCode)
+
+	_, err = sim.Order("unknown")
+	assert.Equal(t, ErrOrderNotFound, err)
+
+	gateway = newTestGateway(sim, newKeyPair(t), danaKey)
+	detail, err = gateway.OrderDetail(&dana.OrderDetailRequestData{MerchantID: testMerchantID, AcquirementID: "unknown"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, RESULT_CODE_INVALID_SIGNATURE, detail.Response.Body.ResultInfo.ResultCode)
+}
+
+func TestServerNotifiesInTheBackground(t *testing.T) {
+	danaKey, merchantKey := newKeyPair(t), newKeyPair(t)
+	release := make(chan struct{})
+	merchant, received, errs := newMerchant(t, danaKey, merchantKey, func(*dana.ResponsePayFinish) { <-release })
+	defer merchant.Close()
+
+	sim := NewServer(danaKey.private, merchantKey.public)
+	defer sim.Close()
+	gateway := newTestGateway(sim, merchantKey, danaKey)
+
+	req, err := dana.NewOrderBuilder(testMerchantID, "ORDER-3").
+		Title("Donation").
+		ProductCode("51051000100000000001").
+		Amount(10000).
+		NotificationURL(merchant.URL).
+		Build()
+	require.NoError(t, err)
+
+	order, err := gateway.Order(req, "")
+	require.NoError(t, err)
+
+	// Pay returns while the merchant is still handling the notification
+	paid := make(chan error, 1)
+	go func() { paid <- sim.Pay(order.Response.Body.AcquirementID) }()
+	select {
+	case err = <-paid:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pay waited for the merchant")
+	}
+	assert.Equal(t, dana.ACQUIREMENT_STATUS_SUCCESS, (<-received).AcquirementStatus)
+
+	close(release)
+	notifications := sim.Notifications()
+	require.Len(t, notifications, 1)
+	assert.NoError(t, notifications[0].Err)
+	assertNoMerchantErrors(t, errs)
+}
@@ -0,0 +1,355 @@
+package dana
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SNAP_ACCESS_TOKEN_PATH = "v1.0/access-token/b2b.htm"
+
+	SNAP_GRANT_TYPE_CLIENT_CREDENTIALS = "client_credentials"
+
+	SNAP_HEADER_TIMESTAMP   = "X-TIMESTAMP"
+	SNAP_HEADER_SIGNATURE   = "X-SIGNATURE"
+	SNAP_HEADER_CLIENT_KEY  = "X-CLIENT-KEY"
+	SNAP_HEADER_PARTNER_ID  = "X-PARTNER-ID"
+	SNAP_HEADER_EXTERNAL_ID = "X-EXTERNAL-ID"
+	SNAP_HEADER_CHANNEL_ID  = "CHANNEL-ID"
+
+	// a token is renewed this long before DANA expires it, so it cannot expire in flight. The margin is at
+	// most a tenth of the token lifetime, so a short lived token is still reused.
+	snapTokenExpiryMargin         = 30 * time.Second
+	snapTokenExpiryMarginFraction = 10
+)
+
+type snapCallKey struct{}
+
+// withSnapCall marks the requests sent with ctx as SnapGateway calls, whose responses are not signed envelopes
+func withSnapCall(ctx context.Context) context.Context {
+	return context.WithValue(ctx, snapCallKey{}, true)
+}
+
+func isSnapCall(ctx context.Context) bool {
+	snap, _ := ctx.Value(snapCallKey{}).(bool)
+	return snap
+}
+
+// SnapResponseCode is the seven digit SNAP response code: the HTTP status, the service code and the case code.
+// For example "2005400" is HTTP 200 for service 54 with case 00.
+type SnapResponseCode string
+
+// HTTPStatus returns the HTTP status part of the code, or 0 when the code is malformed
+func (c SnapResponseCode) HTTPStatus() int {
+	if len(c) != 7 {
+		return 0
+	}
+
+	status, err := strconv.Atoi(string(c[:3]))
+	if err != nil {
+		return 0
+	}
+	return status
+}
+
+// ServiceCode returns the two digit service code
+func (c SnapResponseCode) ServiceCode() string {
+	if len(c) != 7 {
+		return ""
+	}
+	return string(c[3:5])
+}
+
+// CaseCode returns the two digit case code
+func (c SnapResponseCode) CaseCode() string {
+	if len(c) != 7 {
+		return ""
+	}
+	return string(c[5:])
+}
+
+// IsSuccess reports whether the code carries a 2xx HTTP status
+func (c SnapResponseCode) IsSuccess() bool {
+	status := c.HTTPStatus()
+	return status >= 200 && status < 300
+}
+
+type SnapResponse struct {
+	ResponseCode    SnapResponseCode `json:"responseCode"`
+	ResponseMessage string           `json:"responseMessage"`
+}
+
+type SnapAccessTokenRequest struct {
+	GrantType      string                 `json:"grantType"`
+	AdditionalInfo map[string]interface{} `json:"additionalInfo,omitempty"`
+}
+
+type SnapAccessTokenResponse struct {
+	SnapResponse
+	AccessToken    string                 `json:"accessToken"`
+	TokenType      string                 `json:"tokenType"`
+	ExpiresIn      string                 `json:"expiresIn"`
+	AdditionalInfo map[string]interface{} `json:"additionalInfo,omitempty"`
+}
+
+// SnapGateway calls DANA endpoints following the Bank Indonesia SNAP standard. Its Client must use
+// PROTOCOL_SNAP. The B2B access token is requested on first use and renewed when it expires.
+type SnapGateway struct {
+	Client Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+	fetching    *snapTokenFetch
+}
+
+// snapTokenFetch is a token request in flight, the callers arriving meanwhile wait for done and share its result
+type snapTokenFetch struct {
+	done      chan struct{}
+	token     string
+	expiresAt time.Time
+	err       error
+}
+
+// AccessToken : return the cached B2B access token, requesting a new one when there is none or it has expired.
+// Concurrent callers share a single request, and the lock is not held while it is in flight.
+func (gateway *SnapGateway) AccessToken() (token string, err error) {
+	gateway.mu.Lock()
+	if gateway.accessToken != "" && gateway.Client.now().Before(gateway.expiresAt) {
+		token = gateway.accessToken
+		gateway.mu.Unlock()
+		return token, nil
+	}
+
+	fetch := gateway.fetching
+	if fetch != nil {
+		gateway.mu.Unlock()
+		<-fetch.done
+		return fetch.token, fetch.err
+	}
+
+	fetch = &snapTokenFetch{done: make(chan struct{})}
+	gateway.fetching = fetch
+	gateway.mu.Unlock()
+
+	fetch.token, fetch.expiresAt, fetch.err = gateway.fetchAccessToken()
+
+	gateway.mu.Lock()
+	if fetch.err == nil {
+		gateway.accessToken = fetch.token
+		gateway.expiresAt = fetch.expiresAt
+	}
+	gateway.fetching = nil
+	gateway.mu.Unlock()
+	close(fetch.done)
+
+	return fetch.token, fetch.err
+}
+
+func (gateway *SnapGateway) fetchAccessToken() (token string, expiresAt time.Time, err error) {
+	res, err := gateway.ApplyB2BAccessToken()
+	if err != nil {
+		return
+	}
+
+	if !res.ResponseCode.IsSuccess() {
+		err = fmt.Errorf("failed to apply b2b access token: %s %s", res.ResponseCode, res.ResponseMessage)
+		return
+	}
+
+	expiresIn, err := strconv.Atoi(res.ExpiresIn)
+	if err != nil {
+		err = fmt.Errorf("invalid b2b access token expiresIn %q", res.ExpiresIn)
+		return
+	}
+
+	lifetime := time.Duration(expiresIn) * time.Second
+	margin := snapTokenExpiryMargin
+	if max := lifetime / snapTokenExpiryMarginFraction; margin > max {
+		margin = max
+	}
+
+	return res.AccessToken, gateway.Client.now().Add(lifetime - margin), nil
+}
+
+// ApplyB2BAccessToken : request a new B2B access token, signed with the merchant private key over clientId|timestamp
+func (gateway *SnapGateway) ApplyB2BAccessToken() (res SnapAccessTokenResponse, err error) {
+	if err = gateway.checkProtocol(); err != nil {
+		return
+	}
+
+	timestamp := DanaTime{Time: gateway.Client.now()}.String()
+
+	sig, err := generateSnapAsymmetricSignature(gateway.Client.ClientId, timestamp, gateway.Client.PrivateKey)
+	if err != nil {
+		err = fmt.Errorf("failed to generate signature: %v", err)
+		return
+	}
+
+	reqJson, err := json.Marshal(SnapAccessTokenRequest{GrantType: SNAP_GRANT_TYPE_CLIENT_CREDENTIALS})
+	if err != nil {
+		return
+	}
+
+	headers := map[string]string{
+		"Content-Type":         "application/json",
+		SNAP_HEADER_TIMESTAMP:  timestamp,
+		SNAP_HEADER_CLIENT_KEY: gateway.Client.ClientId,
+		SNAP_HEADER_SIGNATURE:  sig,
+	}
+
+	err = gateway.call(context.Background(), "POST", SNAP_ACCESS_TOKEN_PATH, headers, reqJson, &res)
+	return
+}
+
+// Call : send a transactional SNAP request. reqBody is sent as minified JSON, and the response is decoded
+// into v, which should embed SnapResponse so that the caller can check ResponseCode.
+func (gateway *SnapGateway) Call(method, path string, reqBody interface{}, v interface{}) (err error) {
+	return gateway.CallContext(context.Background(), method, path, reqBody, v)
+}
+
+// CallContext : same as Call, sending the request with ctx
+func (gateway *SnapGateway) CallContext(ctx context.Context, method, path string, reqBody interface{}, v interface{}) (err error) {
+	ctx, span := gateway.Client.startSpan(ctx, "dana.snap.Call")
+	defer func() { endSpan(span, err) }()
+
+	if err = gateway.checkProtocol(); err != nil {
+		return
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	token, err := gateway.AccessToken()
+	if err != nil {
+		return
+	}
+
+	var reqJson []byte
+	if reqBody != nil {
+		reqJson, err = json.Marshal(reqBody)
+		if err != nil {
+			return
+		}
+	}
+
+	externalID, err := newSnapExternalID()
+	if err != nil {
+		return
+	}
+
+	timestamp := DanaTime{Time: gateway.Client.now()}.String()
+	_, signSpan := gateway.Client.startSpan(ctx, SPAN_SIGN)
+	stringToSign := snapStringToSign(method, path, token, reqJson, timestamp)
+	sig := generateSnapSymmetricSignature(stringToSign, gateway.Client.ClientSecret)
+	signSpan.End()
+
+	partnerID := gateway.Client.PartnerId
+	if partnerID == "" {
+		partnerID = gateway.Client.ClientId
+	}
+
+	headers := map[string]string{
+		"Content-Type":          "application/json",
+		"Authorization":         "Bearer " + token,
+		SNAP_HEADER_TIMESTAMP:   timestamp,
+		SNAP_HEADER_SIGNATURE:   sig,
+		SNAP_HEADER_PARTNER_ID:  partnerID,
+		SNAP_HEADER_EXTERNAL_ID: externalID,
+		SNAP_HEADER_CHANNEL_ID:  gateway.Client.ChannelId,
+	}
+
+	summary := &callSummary{ReqMsgID: externalID}
+	summary.setIdentifiers(reqJson)
+
+	err = gateway.call(withCallSummary(ctx, summary), method, path, headers, reqJson, v)
+	setResultAttributes(ctx, summary)
+	return
+}
+
+func (gateway *SnapGateway) call(ctx context.Context, method, path string, headers map[string]string, body []byte, v interface{}) error {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return gateway.Client.CallContext(withSnapCall(ctx), method, gateway.Client.BaseUrl+path, headers, bytes.NewReader(body), v)
+}
+
+func (gateway *SnapGateway) checkProtocol() error {
+	if gateway.Client.Protocol != PROTOCOL_SNAP {
+		return fmt.Errorf("snap gateway requires a client using protocol %s, got %q", PROTOCOL_SNAP, gateway.Client.Protocol)
+	}
+	return nil
+}
+
+// generateSnapAsymmetricSignature signs clientId|timestamp with SHA256withRSA, as required to apply a B2B access token
+func generateSnapAsymmetricSignature(clientID string, timestamp string, privateKey []byte) (string, error) {
+	signer, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("signer is damaged: %v", err)
+	}
+
+	signed, err := signer.Sign([]byte(clientID + "|" + timestamp))
+	if err != nil {
+		return "", fmt.Errorf("could not sign request: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signed), nil
+}
+
+// generateSnapSymmetricSignature signs a transactional string to sign with HMAC-SHA512 keyed by the client secret
+func generateSnapSymmetricSignature(stringToSign string, clientSecret string) string {
+	mac := hmac.New(sha512.New, []byte(clientSecret))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// snapStringToSign builds method:path:accessToken:lowercase(hex(sha256(minified body))):timestamp.
+// The access token part is left out when accessToken is empty, which is the form used by notifications.
+func snapStringToSign(method string, path string, accessToken string, body []byte, timestamp string) string {
+	parts := []string{strings.ToUpper(method), path}
+	if accessToken != "" {
+		parts = append(parts, accessToken)
+	}
+	parts = append(parts, snapBodyHash(body), timestamp)
+
+	return strings.Join(parts, ":")
+}
+
+func snapBodyHash(body []byte) string {
+	minified := body
+	if len(body) > 0 {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, body); err == nil {
+			minified = buf.Bytes()
+		}
+	}
+
+	sum := sha256.Sum256(minified)
+	return strings.ToLower(hex.EncodeToString(sum[:]))
+}
+
+// newSnapExternalID returns a random 32 digit X-EXTERNAL-ID, DANA requires it to be numeric and unique per day
+func newSnapExternalID() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(32), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate external id: %v", err)
+	}
+	id := n.String()
+	return strings.Repeat("0", 32-len(id)) + id, nil
+}
@@ -0,0 +1,128 @@
+package dana
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+const (
+	SNAP_SERVICE_CODE_FINISH_NOTIFY = "56"
+
+	SNAP_CASE_SUCCESS              = "00"
+	SNAP_CASE_INVALID_FIELD        = "01"
+	SNAP_CASE_MISSING_FIELD        = "02"
+	SNAP_CASE_UNAUTHORIZED         = "00"
+	SNAP_MESSAGE_SUCCESS           = "Successful"
+	SNAP_MESSAGE_INVALID_FIELD     = "Invalid Field Format"
+	SNAP_MESSAGE_MISSING_FIELD     = "Invalid Mandatory Field"
+	SNAP_MESSAGE_INVALID_SIGNATURE = "Unauthorized. Invalid Signature"
+)
+
+var (
+	ErrSnapMissingHeader    = errors.New("missing snap signature or timestamp header")
+	ErrSnapInvalidSignature = errors.New("invalid snap signature")
+)
+
+// NewSnapResponseCode : build a response code from an HTTP status, a service code and a case code
+func NewSnapResponseCode(httpStatus int, serviceCode string, caseCode string) SnapResponseCode {
+	return SnapResponseCode(strconv.Itoa(httpStatus) + serviceCode + caseCode)
+}
+
+// VerifyNotification : verify the X-SIGNATURE of a SNAP notification sent by DANA against the client's DANA public key.
+// The request body is returned and left readable on r.
+func (gateway *SnapGateway) VerifyNotification(r *http.Request) (body []byte, err error) {
+	body, err = VerifySnapNotification(r, gateway.Client.PublicKey)
+	gateway.Client.observeWebhook(WEBHOOK_KIND_SNAP, err)
+	return
+}
+
+// VerifySnapNotification : verify the X-SIGNATURE of a SNAP notification, a SHA256withRSA signature over
+// method:path:lowercase(hex(sha256(minified body))):timestamp. The request body is returned and left readable on r.
+func VerifySnapNotification(r *http.Request, publicKey []byte) (body []byte, err error) {
+	body, err = ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read notification body: %v", err)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	timestamp := r.Header.Get(SNAP_HEADER_TIMESTAMP)
+	signature := r.Header.Get(SNAP_HEADER_SIGNATURE)
+	if timestamp == "" || signature == "" {
+		return body, ErrSnapMissingHeader
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return body, ErrSnapInvalidSignature
+	}
+
+	unsigner, err := parsePublicKey(publicKey)
+	if err != nil {
+		return body, fmt.Errorf("could not load public key: %v", err)
+	}
+
+	stringToSign := snapStringToSign(r.Method, r.URL.RequestURI(), "", body, timestamp)
+	if err = unsigner.Unsign([]byte(stringToSign), sig); err != nil {
+		return body, ErrSnapInvalidSignature
+	}
+
+	return body, nil
+}
+
+// GenerateSnapNotificationSignature : sign a notification the way DANA does, so a webhook can be tested with a locally
+// generated key pair
+func GenerateSnapNotificationSignature(method string, path string, body []byte, timestamp string, privateKey []byte) (string, error) {
+	signer, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("signer is damaged: %v", err)
+	}
+
+	signed, err := signer.Sign([]byte(snapStringToSign(method, path, "", body, timestamp)))
+	if err != nil {
+		return "", fmt.Errorf("could not sign notification: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signed), nil
+}
+
+// WriteSnapResponse : write a SNAP response, using the HTTP status carried by res.ResponseCode
+func WriteSnapResponse(w http.ResponseWriter, res SnapResponse) error {
+	status := res.ResponseCode.HTTPStatus()
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(res)
+}
+
+// WriteSnapNotificationResponse : answer a SNAP notification for the given service code. A nil err acknowledges it,
+// signature errors from VerifySnapNotification answer 401 and any other error answers an invalid field format.
+func WriteSnapNotificationResponse(w http.ResponseWriter, serviceCode string, err error) error {
+	res := SnapResponse{
+		ResponseCode:    NewSnapResponseCode(http.StatusOK, serviceCode, SNAP_CASE_SUCCESS),
+		ResponseMessage: SNAP_MESSAGE_SUCCESS,
+	}
+
+	switch err {
+	case nil:
+	case ErrSnapInvalidSignature:
+		res.ResponseCode = NewSnapResponseCode(http.StatusUnauthorized, serviceCode, SNAP_CASE_UNAUTHORIZED)
+		res.ResponseMessage = SNAP_MESSAGE_INVALID_SIGNATURE
+	case ErrSnapMissingHeader:
+		res.ResponseCode = NewSnapResponseCode(http.StatusBadRequest, serviceCode, SNAP_CASE_MISSING_FIELD)
+		res.ResponseMessage = SNAP_MESSAGE_MISSING_FIELD
+	default:
+		res.ResponseCode = NewSnapResponseCode(http.StatusBadRequest, serviceCode, SNAP_CASE_INVALID_FIELD)
+		res.ResponseMessage = SNAP_MESSAGE_INVALID_FIELD
+	}
+
+	return WriteSnapResponse(w, res)
+}
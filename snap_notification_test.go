@@ -0,0 +1,64 @@
+package dana
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySnapNotification(t *testing.T) {
+	danaKey := newTestKeyPair(t)
+	other := newTestKeyPair(t)
+
+	client := NewClient()
+	client.Protocol = PROTOCOL_SNAP
+	client.PublicKey = danaKey.public
+	gateway := &SnapGateway{Client: client}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := gateway.VerifyNotification(r)
+		_ = WriteSnapNotificationResponse(w, SNAP_SERVICE_CODE_FINISH_NOTIFY, err)
+	})
+
+	body := []byte(`{"originalPartnerReferenceNo": "ORDER-1", "latestTransactionStatus": "00"}`)
+	timestamp := "2020-10-01T11:12:12+07:00"
+	path := "/dana/notify?source=snap"
+
+	tests := []struct {
+		name       string
+		key        []byte
+		timestamp  string
+		wantStatus int
+		wantCode   SnapResponseCode
+	}{
+		{"valid", danaKey.private, timestamp, http.StatusOK, "2005600"},
+		{"wrong key", other.private, timestamp, http.StatusUnauthorized, "4015600"},
+		{"tampered timestamp", danaKey.private, "2020-10-01T11:12:13+07:00", http.StatusUnauthorized, "4015600"},
+		{"missing signature", nil, timestamp, http.StatusBadRequest, "4005602"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+			req.Header.Set(SNAP_HEADER_TIMESTAMP, tt.timestamp)
+			if tt.key != nil {
+				sig, err := GenerateSnapNotificationSignature("POST", path, body, timestamp, tt.key)
+				require.NoError(t, err)
+				req.Header.Set(SNAP_HEADER_SIGNATURE, sig)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			var res SnapResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantCode, res.ResponseCode)
+		})
+	}
+}
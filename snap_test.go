@@ -0,0 +1,205 @@
+package dana
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type snapQueryRequest struct {
+	OriginalPartnerReferenceNo string `json:"originalPartnerReferenceNo"`
+	ServiceCode                string `json:"serviceCode"`
+}
+
+type snapQueryResponse struct {
+	SnapResponse
+	LatestTransactionStatus string `json:"latestTransactionStatus"`
+}
+
+func TestSnapGatewayCall(t *testing.T) {
+	merchant := newTestKeyPair(t)
+	tokenRequests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp := r.Header.Get(SNAP_HEADER_TIMESTAMP)
+		sig, _ := base64.StdEncoding.DecodeString(r.Header.Get(SNAP_HEADER_SIGNATURE))
+
+		switch r.URL.Path {
+		case "/" + SNAP_ACCESS_TOKEN_PATH:
+			tokenRequests++
+			assert.Equal(t, "snap-client", r.Header.Get(SNAP_HEADER_CLIENT_KEY))
+			assert.NoError(t, verifySignature("snap-client|"+timestamp, base64.StdEncoding.EncodeToString(sig), merchant.public))
+
+			_ = json.NewEncoder(w).Encode(SnapAccessTokenResponse{
+				SnapResponse: SnapResponse{ResponseCode: "2007300", ResponseMessage: "Successful"},
+				AccessToken:  "b2b-token",
+				TokenType:    "Bearer",
+				ExpiresIn:    "900",
+			})
+		case "/v1.0/debit/status.htm":
+			assert.Equal(t, "Bearer b2b-token", r.Header.Get("Authorization"))
+			assert.Equal(t, "snap-client", r.Header.Get(SNAP_HEADER_PARTNER_ID))
+			assert.Equal(t, "95221", r.Header.Get(SNAP_HEADER_CHANNEL_ID))
+			assert.Len(t, r.Header.Get(SNAP_HEADER_EXTERNAL_ID), 32)
+
+			want := generateSnapSymmetricSignature(snapStringToSign("POST", r.URL.Path, "b2b-token", body, timestamp), "snap-secret")
+			assert.Equal(t, want, r.Header.Get(SNAP_HEADER_SIGNATURE))
+
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(SnapResponse{ResponseCode: "4045501", ResponseMessage: "Transaction Not Found"})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.BaseUrl = server.URL
+	client.ClientId = "snap-client"
+	client.ClientSecret = "snap-secret"
+	client.ChannelId = "95221"
+	client.PrivateKey = merchant.private
+	client.Protocol = PROTOCOL_SNAP
+
+	gateway := &SnapGateway{Client: client}
+
+	for i := 0; i < 2; i++ {
+		var res snapQueryResponse
+		err := gateway.Call("POST", "v1.0/debit/status.htm", snapQueryRequest{OriginalPartnerReferenceNo: "ORDER-1", ServiceCode: "54"}, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, SnapResponseCode("4045501"), res.ResponseCode)
+		assert.Equal(t, http.StatusNotFound, res.ResponseCode.HTTPStatus())
+		assert.Equal(t, "55", res.ResponseCode.ServiceCode())
+		assert.Equal(t, "01", res.ResponseCode.CaseCode())
+		assert.False(t, res.ResponseCode.IsSuccess())
+	}
+
+	assert.Equal(t, 1, tokenRequests, "access token should be cached")
+}
+
+// newSnapTokenServer answers token requests with expiresIn, after release is closed when it is not nil
+func newSnapTokenServer(expiresIn string, release chan struct{}, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if release != nil {
+			<-release
+		}
+
+		_ = json.NewEncoder(w).Encode(SnapAccessTokenResponse{
+			SnapResponse: SnapResponse{ResponseCode: "2007300"},
+			AccessToken:  "b2b-token",
+			ExpiresIn:    expiresIn,
+		})
+	}))
+}
+
+func newSnapTestGateway(t *testing.T, baseURL string) *SnapGateway {
+	client := NewClient()
+	client.LogLevel = 0
+	client.BaseUrl = baseURL
+	client.ClientId = "snap-client"
+	client.PrivateKey = newTestKeyPair(t).private
+	client.Protocol = PROTOCOL_SNAP
+
+	return &SnapGateway{Client: client}
+}
+
+// Run with -race: the callers arriving while a token request is in flight share it
+func TestSnapAccessTokenConcurrentCallers(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	server := newSnapTokenServer("900", release, &requests)
+	defer server.Close()
+
+	gateway := newSnapTestGateway(t, server.URL)
+
+	const callers = 20
+	var wg sync.WaitGroup
+	tokens := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := gateway.AccessToken()
+			assert.NoError(t, err)
+			tokens[i] = token
+		}(i)
+	}
+
+	// the lock is free while the request is in flight
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == 1 }, time.Second, time.Millisecond)
+	gateway.mu.Lock()
+	assert.NotNil(t, gateway.fetching)
+	gateway.mu.Unlock()
+
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+	for _, token := range tokens {
+		assert.Equal(t, "b2b-token", token)
+	}
+}
+
+func TestSnapAccessTokenShortLifetime(t *testing.T) {
+	var requests int32
+	server := newSnapTokenServer("20", nil, &requests)
+	defer server.Close()
+
+	now := time.Date(2020, 10, 1, 4, 0, 0, 0, time.UTC)
+	gateway := newSnapTestGateway(t, server.URL)
+	gateway.Client.Clock = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := gateway.AccessToken()
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests), "a 20s token is reused")
+
+	// renewed a tenth of its lifetime before it expires
+	now = now.Add(18 * time.Second)
+	_, err := gateway.AccessToken()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+}
+
+func TestCoreGatewayOnSnapClientVerifiesResponses(t *testing.T) {
+	fake := newFakeDana(t, func(path string, req Request) interface{} {
+		return OrderDetailData{ResultInfo: ResultInfo{ResultStatus: "S"}}
+	})
+	defer fake.Close()
+
+	gateway := fake.gateway()
+	gateway.Client.LogLevel = 0
+	gateway.Client.Protocol = PROTOCOL_SNAP
+	// the responses signed by the fake do not verify with another key, as a forged response would not
+	gateway.Client.PublicKey = newTestKeyPair(t).public
+
+	_, err := gateway.OrderDetail(&OrderDetailRequestData{MerchantID: "m", MerchantTransID: "ORDER-1"}, "")
+	assert.Error(t, err)
+}
+
+func TestSnapGatewayRequiresSnapClient(t *testing.T) {
+	gateway := &SnapGateway{Client: NewClient()}
+
+	_, err := gateway.ApplyB2BAccessToken()
+	assert.Error(t, err)
+}
+
+func TestSnapStringToSign(t *testing.T) {
+	body := []byte("{\n  \"a\": 1\n}")
+	got := snapStringToSign("post", "/v1.0/debit/status.htm", "token", body, "2020-01-01T00:00:00+07:00")
+
+	assert.Equal(t, "POST:/v1.0/debit/status.htm:token:"+snapBodyHash([]byte(`{"a":1}`))+":2020-01-01T00:00:00+07:00", got)
+}
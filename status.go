@@ -0,0 +1,188 @@
+package dana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AcquirementStatus is the status of an order (acquirement) on DANA
+type AcquirementStatus string
+
+const (
+	ACQUIREMENT_STATUS_INIT            AcquirementStatus = "INIT"
+	ACQUIREMENT_STATUS_PAYING          AcquirementStatus = "PAYING"
+	ACQUIREMENT_STATUS_MERCHANT_ACCEPT AcquirementStatus = "MERCHANT_ACCEPT"
+	ACQUIREMENT_STATUS_SUCCESS         AcquirementStatus = "SUCCESS"
+	ACQUIREMENT_STATUS_CLOSED          AcquirementStatus = "CLOSED"
+	ACQUIREMENT_STATUS_CANCELLED       AcquirementStatus = "CANCELLED"
+)
+
+// ErrIllegalStatusTransition is returned, wrapped, when a status would move an order backwards or out of a final status
+var ErrIllegalStatusTransition = errors.New("illegal acquirement status transition")
+
+// acquirementTransitions lists the statuses each status may move to, besides itself.
+// SUCCESS, CLOSED and CANCELLED are final.
+var acquirementTransitions = map[AcquirementStatus][]AcquirementStatus{
+	ACQUIREMENT_STATUS_INIT: {
+		ACQUIREMENT_STATUS_PAYING,
+		ACQUIREMENT_STATUS_MERCHANT_ACCEPT,
+		ACQUIREMENT_STATUS_SUCCESS,
+		ACQUIREMENT_STATUS_CLOSED,
+		ACQUIREMENT_STATUS_CANCELLED,
+	},
+	ACQUIREMENT_STATUS_PAYING: {
+		ACQUIREMENT_STATUS_MERCHANT_ACCEPT,
+		ACQUIREMENT_STATUS_SUCCESS,
+		ACQUIREMENT_STATUS_CLOSED,
+		ACQUIREMENT_STATUS_CANCELLED,
+	},
+	ACQUIREMENT_STATUS_MERCHANT_ACCEPT: {
+		ACQUIREMENT_STATUS_SUCCESS,
+		ACQUIREMENT_STATUS_CANCELLED,
+	},
+	ACQUIREMENT_STATUS_SUCCESS:   {},
+	ACQUIREMENT_STATUS_CLOSED:    {},
+	ACQUIREMENT_STATUS_CANCELLED: {},
+}
+
+// ParseAcquirementStatus : parse s case insensitively, unknown statuses are an error
+func ParseAcquirementStatus(s string) (status AcquirementStatus, err error) {
+	status = AcquirementStatus(strings.ToUpper(strings.TrimSpace(s)))
+	if !status.IsValid() {
+		return "", fmt.Errorf("unknown acquirement status %q", s)
+	}
+
+	return
+}
+
+// IsValid : whether s is one of the statuses DANA documents
+func (s AcquirementStatus) IsValid() bool {
+	_, ok := acquirementTransitions[s]
+	return ok
+}
+
+// IsFinal : whether the order can no longer change status
+func (s AcquirementStatus) IsFinal() bool {
+	return s == ACQUIREMENT_STATUS_SUCCESS || s == ACQUIREMENT_STATUS_CLOSED || s == ACQUIREMENT_STATUS_CANCELLED
+}
+
+// IsPaid : whether the user has paid for the order
+func (s AcquirementStatus) IsPaid() bool {
+	return s == ACQUIREMENT_STATUS_SUCCESS || s == ACQUIREMENT_STATUS_MERCHANT_ACCEPT
+}
+
+// CanTransitionTo : whether an order in status s may move to next. Staying in the same status is allowed,
+// so repeated notifications and queries are harmless.
+func (s AcquirementStatus) CanTransitionTo(next AcquirementStatus) bool {
+	if !s.IsValid() || !next.IsValid() {
+		return false
+	}
+
+	if s == next {
+		return true
+	}
+
+	for _, allowed := range acquirementTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Transition : move from s to next, returning next, or an error wrapping ErrIllegalStatusTransition
+// when the move is not allowed, e.g. SUCCESS to INIT from a stale notification
+func (s AcquirementStatus) Transition(next AcquirementStatus) (AcquirementStatus, error) {
+	if !next.IsValid() {
+		return s, fmt.Errorf("unknown acquirement status %q", string(next))
+	}
+
+	if !s.CanTransitionTo(next) {
+		return s, fmt.Errorf("%w: %s to %s", ErrIllegalStatusTransition, s, next)
+	}
+
+	return next, nil
+}
+
+func (s *AcquirementStatus) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("acquirement status must be a string, got %s", data)
+	}
+
+	// unknown statuses are kept as they are, so a new status from DANA doesn't fail the whole response
+	*s = AcquirementStatus(strings.ToUpper(strings.TrimSpace(value)))
+	return nil
+}
+
+// OrderState is the status of an order as the merchant has recorded it. Apply query results and
+// notifications to it to keep the record moving forward only.
+type OrderState struct {
+	AcquirementID   string
+	MerchantTransID string
+	Status          AcquirementStatus
+}
+
+// NewOrderState : the state of an order just created with Order
+func NewOrderState(acquirementID, merchantTransID string) *OrderState {
+	return &OrderState{
+		AcquirementID:   acquirementID,
+		MerchantTransID: merchantTransID,
+		Status:          ACQUIREMENT_STATUS_INIT,
+	}
+}
+
+// Apply : move the order to next, an illegal transition leaves the state unchanged.
+// changed tells whether the status is different from before.
+func (o *OrderState) Apply(next AcquirementStatus) (changed bool, err error) {
+	if o.Status == "" {
+		o.Status = ACQUIREMENT_STATUS_INIT
+	}
+
+	status, err := o.Status.Transition(next)
+	if err != nil {
+		return false, fmt.Errorf("order %s: %w", o.MerchantTransID, err)
+	}
+
+	changed = status != o.Status
+	o.Status = status
+	return
+}
+
+// ApplyOrderDetail : apply the status returned by OrderDetail
+func (o *OrderState) ApplyOrderDetail(detail OrderDetailData) (changed bool, err error) {
+	if err = o.checkOrder(detail.AcquirementID, detail.MerchantTransID); err != nil {
+		return
+	}
+
+	return o.Apply(detail.StatusDetail.AcquirementStatus)
+}
+
+// ApplyPayFinish : apply the status sent in a finish payment notification
+func (o *OrderState) ApplyPayFinish(notification RequestBodyPayFinish) (changed bool, err error) {
+	if err = o.checkOrder(notification.AcquirementID, notification.MerchantTransID); err != nil {
+		return
+	}
+
+	return o.Apply(notification.AcquirementStatus)
+}
+
+// checkOrder refuses statuses of another order, ids unknown on either side are not compared
+func (o *OrderState) checkOrder(acquirementID, merchantTransID string) error {
+	if o.AcquirementID != "" && acquirementID != "" && o.AcquirementID != acquirementID {
+		return fmt.Errorf("status is for acquirement %s, not %s", acquirementID, o.AcquirementID)
+	}
+
+	if o.MerchantTransID != "" && merchantTransID != "" && o.MerchantTransID != merchantTransID {
+		return fmt.Errorf("status is for order %s, not %s", merchantTransID, o.MerchantTransID)
+	}
+
+	if o.AcquirementID == "" {
+		o.AcquirementID = acquirementID
+	}
+
+	return nil
+}
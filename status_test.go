@@ -0,0 +1,78 @@
+package dana
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquirementStatusHelpers(t *testing.T) {
+	assert.True(t, ACQUIREMENT_STATUS_SUCCESS.IsFinal())
+	assert.True(t, ACQUIREMENT_STATUS_CANCELLED.IsFinal())
+	assert.False(t, ACQUIREMENT_STATUS_PAYING.IsFinal())
+	assert.True(t, ACQUIREMENT_STATUS_MERCHANT_ACCEPT.IsPaid())
+	assert.False(t, ACQUIREMENT_STATUS_CLOSED.IsPaid())
+
+	status, err := ParseAcquirementStatus(" success ")
+	require.NoError(t, err)
+	assert.Equal(t, ACQUIREMENT_STATUS_SUCCESS, status)
+
+	_, err = ParseAcquirementStatus("REFUNDED")
+	assert.Error(t, err)
+}
+
+func TestAcquirementStatusJSON(t *testing.T) {
+	var detail StatusDetail
+	require.NoError(t, json.Unmarshal([]byte(`{"acquirementStatus":"paying"}`), &detail))
+	assert.Equal(t, ACQUIREMENT_STATUS_PAYING, detail.AcquirementStatus)
+
+	// a status DANA adds later doesn't fail decoding, but is not valid
+	require.NoError(t, json.Unmarshal([]byte(`{"acquirementStatus":"ON_HOLD"}`), &detail))
+	assert.False(t, detail.AcquirementStatus.IsValid())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"acquirementStatus":1}`), &detail))
+
+	data, err := json.Marshal(RequestBodyPayFinish{AcquirementStatus: ACQUIREMENT_STATUS_SUCCESS})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"acquirementStatus":"SUCCESS"`)
+}
+
+func TestOrderStateTransitions(t *testing.T) {
+	order := NewOrderState("acq-1", "ORDER-1")
+
+	changed, err := order.ApplyOrderDetail(OrderDetailData{AcquirementID: "acq-1", StatusDetail: StatusDetail{AcquirementStatus: ACQUIREMENT_STATUS_PAYING}})
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	changed, err = order.ApplyPayFinish(RequestBodyPayFinish{MerchantTransID: "ORDER-1", AcquirementStatus: ACQUIREMENT_STATUS_SUCCESS})
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// the same notification delivered twice
+	changed, err = order.ApplyPayFinish(RequestBodyPayFinish{MerchantTransID: "ORDER-1", AcquirementStatus: ACQUIREMENT_STATUS_SUCCESS})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// a stale query result must not move a paid order back
+	_, err = order.ApplyOrderDetail(OrderDetailData{StatusDetail: StatusDetail{AcquirementStatus: ACQUIREMENT_STATUS_INIT}})
+	assert.True(t, errors.Is(err, ErrIllegalStatusTransition))
+	assert.Equal(t, ACQUIREMENT_STATUS_SUCCESS, order.Status)
+
+	_, err = order.ApplyPayFinish(RequestBodyPayFinish{MerchantTransID: "ORDER-2", AcquirementStatus: ACQUIREMENT_STATUS_SUCCESS})
+	assert.Error(t, err)
+
+	_, err = NewOrderState("acq-3", "ORDER-3").Apply("ON_HOLD")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrIllegalStatusTransition))
+}
+
+func TestAcquirementStatusCanTransitionTo(t *testing.T) {
+	assert.True(t, ACQUIREMENT_STATUS_INIT.CanTransitionTo(ACQUIREMENT_STATUS_CLOSED))
+	assert.True(t, ACQUIREMENT_STATUS_MERCHANT_ACCEPT.CanTransitionTo(ACQUIREMENT_STATUS_SUCCESS))
+	assert.False(t, ACQUIREMENT_STATUS_MERCHANT_ACCEPT.CanTransitionTo(ACQUIREMENT_STATUS_PAYING))
+	assert.False(t, ACQUIREMENT_STATUS_CLOSED.CanTransitionTo(ACQUIREMENT_STATUS_SUCCESS))
+	assert.False(t, ACQUIREMENT_STATUS_CANCELLED.CanTransitionTo(ACQUIREMENT_STATUS_INIT))
+}
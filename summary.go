@@ -0,0 +1,142 @@
+package dana
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const LOG_MESSAGE_CALL_SUMMARY = "DANA call"
+
+type callSummaryKey struct{}
+
+// callSummary collects what is known about a single DANA call, so that it can be logged as one event.
+// Gateways fill in what they know before sending, ExecuteRequest adds the outcome.
+type callSummary struct {
+	Function        string
+	Path            string
+	ReqMsgID        string
+	MerchantTransID string
+	RequestID       string
+	HTTPStatus      int
+	ResultStatus    string
+	ResultCodeID    string
+	Latency         time.Duration
+	// Retries is the number of times the call was sent again. The client doesn't retry yet, so it is always
+	// 0, the field keeps the summary's shape stable for when it does.
+	Retries int
+	Err     error
+}
+
+func withCallSummary(ctx context.Context, summary *callSummary) context.Context {
+	return context.WithValue(ctx, callSummaryKey{}, summary)
+}
+
+// callSummaryFromContext returns the summary a gateway attached to ctx, or a new one for direct Client calls
+func callSummaryFromContext(ctx context.Context) *callSummary {
+	if summary, ok := ctx.Value(callSummaryKey{}).(*callSummary); ok {
+		return summary
+	}
+	return &callSummary{}
+}
+
+// setIdentifiers picks the merchant identifiers out of a request body
+func (s *callSummary) setIdentifiers(reqJson []byte) {
+	body := string(reqJson)
+	s.MerchantTransID = firstString(body, "merchantTransId", "order.merchantTransId", "partnerReferenceNo", "originalPartnerReferenceNo")
+	s.RequestID = firstString(body, "requestId")
+}
+
+// setResult picks the result out of a legacy envelope, a V1 response or a SNAP response
+func (s *callSummary) setResult(resBody []byte) {
+	body := string(resBody)
+	s.ResultStatus = firstString(body, "response.body.resultInfo.resultStatus", "result.resultStatus")
+	s.ResultCodeID = firstString(body, "response.body.resultInfo.resultCodeId", "result.resultCodeId", "responseCode")
+}
+
+func (s *callSummary) fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		LOG_KEY_PATH:        s.Path,
+		LOG_KEY_HTTP_STATUS: s.HTTPStatus,
+		LOG_KEY_LATENCY_MS:  s.Latency.Nanoseconds() / int64(time.Millisecond),
+		LOG_KEY_RETRIES:     s.Retries,
+	}
+
+	optional := map[string]string{
+		LOG_KEY_FUNCTION:          s.Function,
+		LOG_KEY_REQ_MSG_ID:        s.ReqMsgID,
+		LOG_KEY_MERCHANT_TRANS_ID: s.MerchantTransID,
+		LOG_KEY_REQUEST_ID:        s.RequestID,
+		LOG_KEY_RESULT_STATUS:     s.ResultStatus,
+		LOG_KEY_RESULT_CODE_ID:    s.ResultCodeID,
+	}
+	for key, value := range optional {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+
+	if s.Err != nil {
+		fields[LOG_KEY_ERROR] = s.Err.Error()
+	}
+
+	return fields
+}
+
+// logCall writes the summary as one event: with structured fields when the logger is a FieldLogger,
+// as key=value pairs in the message otherwise. Failed calls are logged as errors.
+func (c *Client) logCall(ctx context.Context, summary *callSummary) {
+	if summary.Err == nil && c.LogLevel < 2 {
+		return
+	}
+	if summary.Err != nil && c.LogLevel < 1 {
+		return
+	}
+
+	logger := c.logger(ctx)
+	if logger == nil {
+		return
+	}
+
+	fields := summary.fields()
+
+	message := LOG_MESSAGE_CALL_SUMMARY
+	if fieldLogger, ok := logger.(FieldLogger); ok {
+		ctx = WithLogger(ctx, fieldLogger.WithFields(fields))
+	} else {
+		message += " " + formatFields(fields)
+	}
+
+	if summary.Err != nil {
+		c.logError(ctx, "%s", message)
+		return
+	}
+	c.logInfo(ctx, "%s", message)
+}
+
+func formatFields(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, fields[key]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+func firstString(json string, paths ...string) string {
+	for _, path := range paths {
+		if value := gjson.Get(json, path); value.Exists() && value.String() != "" {
+			return value.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package dana
+
+import "context"
+
+const (
+	SPAN_SIGN   = "dana.sign"
+	SPAN_HTTP   = "dana.http"
+	SPAN_VERIFY = "dana.verify"
+
+	ATTRIBUTE_FUNCTION          = "dana.function"
+	ATTRIBUTE_REQ_MSG_ID        = "dana.req_msg_id"
+	ATTRIBUTE_MERCHANT_TRANS_ID = "dana.merchant_trans_id"
+	ATTRIBUTE_RESULT_STATUS     = "dana.result_status"
+	ATTRIBUTE_RESULT_CODE_ID    = "dana.result_code_id"
+	ATTRIBUTE_HTTP_METHOD       = "http.method"
+	ATTRIBUTE_HTTP_STATUS_CODE  = "http.status_code"
+)
+
+// Tracer starts the spans recorded around gateway calls: one per gateway method, named after the
+// method (e.g. "dana.Order"), with children for signing, the HTTP exchange and signature verification.
+// Client.Tracer is nil by default, which disables tracing. Package danaotel provides an OpenTelemetry Tracer.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+type spanKey struct{}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+// startSpan starts a span as a child of the span in ctx. The returned context carries the new span.
+func (c *Client) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+
+	ctx, span := c.Tracer.Start(ctx, name)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// spanFromContext returns the span started by startSpan for ctx, or a span doing nothing
+func spanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+	return noopSpan{}
+}
+
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// WithContext : return a shallow copy of the gateway whose calls use ctx, so that they are traced as part of
+// the trace found in ctx
+func (gateway *CoreGateway) WithContext(ctx context.Context) *CoreGateway {
+	derived := *gateway
+	derived.ctx = ctx
+	return &derived
+}
+
+func (gateway *CoreGateway) startSpan(method string) (context.Context, Span) {
+	ctx := gateway.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return gateway.Client.startSpan(ctx, "dana."+method)
+}
+
+// setResultAttributes records the outcome of a call on the gateway method span
+func setResultAttributes(ctx context.Context, summary *callSummary) {
+	span := spanFromContext(ctx)
+
+	attributes := map[string]string{
+		ATTRIBUTE_FUNCTION:          summary.Function,
+		ATTRIBUTE_REQ_MSG_ID:        summary.ReqMsgID,
+		ATTRIBUTE_MERCHANT_TRANS_ID: summary.MerchantTransID,
+		ATTRIBUTE_RESULT_STATUS:     summary.ResultStatus,
+		ATTRIBUTE_RESULT_CODE_ID:    summary.ResultCodeID,
+	}
+	for key, value := range attributes {
+		if value != "" {
+			span.SetAttribute(key, value)
+		}
+	}
+}